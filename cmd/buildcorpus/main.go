@@ -0,0 +1,90 @@
+// buildcorpus reads raw text, one document per line or one document
+// per file of a directory, and writes the corpus in gotm format with
+// its vocabulary file. Words and documents can be pruned like
+// prunecorpus does, the id mappings are then relative to the ids the
+// builder assigned.
+package main
+
+import (
+	"flag"
+	"os"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+)
+
+var (
+	input     = flag.String("input", "", "raw text file with one document per line, or directory with one document per file")
+	output    = flag.String("output_file", "corpus.txt", "output corpus file")
+	vocab     = flag.String("vocab_file", "vocab.txt", "output vocabulary file, the word of line i has id i counting from zero")
+	stopwords = flag.String("stopword_file", "", "file of stopwords, one per line, builtin english stopwords if empty")
+	keepStop  = flag.Bool("keep_stopwords", false, "whether keep stopwords")
+	minLength = flag.Int("min_length", 1, "min number of characters of tokens")
+
+	minDocFreq   = flag.Uint("min_df", 0, "min number of documents containing a word")
+	maxDocRatio  = flag.Float64("max_df_ratio", 0, "max ratio of documents containing a word, 0 disables")
+	topN         = flag.Int("top_n", 0, "number of most frequent words kept, 0 keeps all")
+	minDocLength = flag.Uint("min_doc_length", 0, "min number of tokens of a document after pruning")
+	wordMap      = flag.String("word_map_file", "", "output file of [oldId newId] word id pairs of pruning")
+	docMap       = flag.String("doc_map_file", "", "output file of [oldId newId] document id pairs of pruning")
+)
+
+func main() {
+	flag.Parse()
+
+	if *input == "" {
+		log.Fatal("input should be given")
+	}
+	b := corpus.NewBuilder()
+	b.MinLength = *minLength
+	if *keepStop {
+		b.Stopwords = make(map[string]bool)
+	} else if *stopwords != "" {
+		if err := b.LoadStopwords(*stopwords); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if info.IsDir() {
+		err = b.AddDir(*input)
+	} else {
+		err = b.AddLines(*input)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dat := b.Corpus()
+	vocabulary := b.Vocab()
+	if *minDocFreq > 0 || *maxDocRatio > 0 || *topN > 0 || *minDocLength > 0 {
+		words, docs := dat.Prune(&corpus.Pruning{
+			MinDocFreq:   uint32(*minDocFreq),
+			MaxDocRatio:  *maxDocRatio,
+			TopN:         *topN,
+			MinDocLength: uint32(*minDocLength),
+		})
+		vocabulary = corpus.RemapVocab(vocabulary, words)
+		if *wordMap != "" {
+			if err := corpus.SaveIdMap(words, *wordMap); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *docMap != "" {
+			if err := corpus.SaveIdMap(docs, *docMap); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if err := dat.Save(*output); err != nil {
+		log.Fatal(err)
+	}
+	if err := corpus.SaveVocab(vocabulary, *vocab); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("%d documents and %d words written", dat.DocNum, dat.VocabSize)
+}
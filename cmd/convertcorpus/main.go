@@ -0,0 +1,35 @@
+// convertcorpus converts a text corpus into the binary corpus format,
+// which is memory mapped and decoded without text parsing when
+// training. Models loading the whole corpus still decode every document
+// into memory, only streaming reads one document at a time.
+package main
+
+import (
+	"flag"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+)
+
+var (
+	input    = flag.String("input_file", "", "input corpus file")
+	inputFmt = flag.String("input_format", "auto", "format of input file: gotm, uci, mm, svmlight, binary or auto")
+	output   = flag.String("output_file", "corpus.bin", "output binary corpus file")
+)
+
+func main() {
+	flag.Parse()
+
+	if *input == "" {
+		log.Fatal("input_file should be given")
+	}
+	dat := &corpus.Corpus{}
+	if err := dat.LoadFormat(*input, *inputFmt); err != nil {
+		log.Fatal(err)
+	}
+	if err := dat.SaveBinary(*output); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("wrote %d documents to %s", dat.DocNum, *output)
+}
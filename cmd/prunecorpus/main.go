@@ -0,0 +1,67 @@
+// prunecorpus removes rare, common or infrequent words and short
+// documents from a corpus in gotm format, remaps word and document ids
+// densely and writes the id mappings.
+package main
+
+import (
+	"flag"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+)
+
+var (
+	input        = flag.String("input_file", "", "input corpus file")
+	inputFmt     = flag.String("input_format", "auto", "format of input file: gotm, uci, mm, svmlight, binary or auto")
+	output       = flag.String("output_file", "pruned.txt", "output corpus file")
+	vocabIn      = flag.String("vocab_file", "", "vocabulary of input corpus, the word of line i has id i")
+	vocabOut     = flag.String("output_vocab_file", "", "output vocabulary file, written if vocab_file is given")
+	wordMap      = flag.String("word_map_file", "word_map.txt", "output file of [oldId newId] word id pairs")
+	docMap       = flag.String("doc_map_file", "doc_map.txt", "output file of [oldId newId] document id pairs")
+	minDocFreq   = flag.Uint("min_df", 0, "min number of documents containing a word")
+	maxDocRatio  = flag.Float64("max_df_ratio", 0, "max ratio of documents containing a word, 0 disables")
+	topN         = flag.Int("top_n", 0, "number of most frequent words kept, 0 keeps all")
+	minDocLength = flag.Uint("min_doc_length", 0, "min number of tokens of a document after pruning")
+)
+
+func main() {
+	flag.Parse()
+
+	if *input == "" {
+		log.Fatal("input_file should be given")
+	}
+	dat := &corpus.Corpus{}
+	if err := dat.LoadFormat(*input, *inputFmt); err != nil {
+		log.Fatal(err)
+	}
+
+	words, docs := dat.Prune(&corpus.Pruning{
+		MinDocFreq:   uint32(*minDocFreq),
+		MaxDocRatio:  *maxDocRatio,
+		TopN:         *topN,
+		MinDocLength: uint32(*minDocLength),
+	})
+	if err := dat.Save(*output); err != nil {
+		log.Fatal(err)
+	}
+	if err := corpus.SaveIdMap(words, *wordMap); err != nil {
+		log.Fatal(err)
+	}
+	if err := corpus.SaveIdMap(docs, *docMap); err != nil {
+		log.Fatal(err)
+	}
+
+	if *vocabIn != "" {
+		if *vocabOut == "" {
+			log.Fatal("output_vocab_file should be given with vocab_file")
+		}
+		vocab, err := corpus.LoadVocab(*vocabIn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := corpus.SaveVocab(corpus.RemapVocab(vocab, words), *vocabOut); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
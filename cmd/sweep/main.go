@@ -0,0 +1,252 @@
+// sweep trains models over a grid of topic numbers and hyperparameters
+// with repeated seeds, scores every run by held-out perplexity and
+// topic coherence, writes a summary table and saves the best model.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/coherence"
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/eval"
+	"github.com/bobonovski/gotm/model"
+)
+
+var (
+	input     = flag.String("input_file", "", "input training file")
+	inputFmt  = flag.String("input_format", "auto", "format of input, held-out and reference files: gotm, uci, mm, svmlight, binary or auto")
+	heldout   = flag.String("heldout_file", "", "held-out file of perplexity evaluation")
+	reference = flag.String("reference_file", "", "reference corpus of topic coherence, training file if empty")
+	modelType = flag.String("model_type", "lda", "model type")
+	topicNums = flag.String("k", "10,20,50", "comma separated numbers of topics")
+	alphas    = flag.String("alpha", "0.01,0.1", "comma separated document-topic mixture hyperparameters")
+	betas     = flag.String("beta", "0.01", "comma separated topic-word mixture hyperparameters")
+	hyper     = flag.String("hyper_params", "", "extra model hyperparameters, e.g. discount=0.5,concentration=10")
+	iteration = flag.Int("iter", 100, "number of training iteration")
+	repeats   = flag.Int("repeats", 3, "number of runs of each setting")
+	seed      = flag.Int64("seed", 1, "seed of the first run, following runs use seed+1, seed+2, ...")
+	particles = flag.Int("particles", 10, "number of particles of left-to-right evaluation")
+	topN      = flag.Int("top_n", 10, "number of top words of topic coherence evaluation")
+	selection = flag.String("select", "perplexity", "metric selecting the best model: perplexity, umass, npmi or c_v")
+	output    = flag.String("output", "sweep", "prefix of summary files, .csv and .json are written")
+	modelName = flag.String("model_file", "best_model", "output name of the best model")
+)
+
+// the setting and scores of one training run
+type Run struct {
+	TopicNum   uint32  `json:"k"`
+	Alpha      float64 `json:"alpha"`
+	Beta       float64 `json:"beta"`
+	Seed       int64   `json:"seed"`
+	Perplexity float64 `json:"perplexity"`
+	Completion float64 `json:"completion_perplexity"`
+	UMass      float64 `json:"umass"`
+	NPMI       float64 `json:"npmi"`
+	CV         float64 `json:"c_v"`
+}
+
+// get the score of run by the selection metric, higher is better
+func (this *Run) score() float64 {
+	switch *selection {
+	case "perplexity":
+		return -this.Perplexity
+	case "umass":
+		return this.UMass
+	case "npmi":
+		return this.NPMI
+	case "c_v":
+		return this.CV
+	}
+	log.Fatalf("unknown selection metric: %s", *selection)
+	return 0.0
+}
+
+// parse comma separated float list
+func parseFloats(s string) []float64 {
+	var vals []float64
+	for _, v := range strings.Split(s, ",") {
+		val, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		vals = append(vals, val)
+	}
+	return vals
+}
+
+// parse comma separated list of topic numbers, values which are not
+// positive integers are rejected
+func parseTopicNums(s string) []uint32 {
+	var vals []uint32
+	for _, v := range strings.Split(s, ",") {
+		val, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+		if err != nil {
+			log.Fatalf("bad number of topics %q: %v", v, err)
+		}
+		if val == 0 {
+			log.Fatal("number of topics should be positive")
+		}
+		vals = append(vals, uint32(val))
+	}
+	return vals
+}
+
+// load corpus from file
+func load(fn string) *corpus.Corpus {
+	dat := &corpus.Corpus{}
+	if err := dat.LoadFormat(fn, *inputFmt); err != nil {
+		log.Fatal(err)
+	}
+	return dat
+}
+
+// train one model and score it
+func train(run *Run, dat, test, ref *corpus.Corpus) model.Model {
+	ctor, err := model.GetModel(*modelType)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m := ctor(run.TopicNum, float32(run.Alpha), float32(run.Beta))
+	if err := model.SetHyperParams(m, *hyper); err != nil {
+		log.Fatal(err)
+	}
+	if s, ok := m.(model.Seeder); ok {
+		s.Seed(run.Seed)
+	} else {
+		log.Warningf("model %s does not support seeding, runs are not reproducible", *modelType)
+	}
+	m.Train(dat, *iteration)
+
+	phi := m.Phi()
+	_, k := phi.Shape()
+	prior := model.DocTopicPrior(m, k, float32(run.Alpha))
+	e := eval.NewEvaluator(phi, prior, rand.New(rand.NewSource(run.Seed)))
+	run.Perplexity = e.LeftToRight(test, *particles).Perplexity()
+	run.Completion = e.DocumentCompletion(test, *iteration).Perplexity()
+
+	c := coherence.New(phi, ref, *topN)
+	run.UMass = coherence.Mean(c.UMass())
+	run.NPMI = coherence.Mean(c.NPMI())
+	run.CV = coherence.Mean(c.CV())
+	return m
+}
+
+// write the runs as csv table
+func writeCSV(runs []*Run, fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"k", "alpha", "beta", "seed", "perplexity",
+		"completion_perplexity", "umass", "npmi", "c_v"})
+	for _, run := range runs {
+		w.Write([]string{
+			strconv.FormatUint(uint64(run.TopicNum), 10),
+			strconv.FormatFloat(run.Alpha, 'g', -1, 64),
+			strconv.FormatFloat(run.Beta, 'g', -1, 64),
+			strconv.FormatInt(run.Seed, 10),
+			strconv.FormatFloat(run.Perplexity, 'f', 6, 64),
+			strconv.FormatFloat(run.Completion, 'f', 6, 64),
+			strconv.FormatFloat(run.UMass, 'f', 6, 64),
+			strconv.FormatFloat(run.NPMI, 'f', 6, 64),
+			strconv.FormatFloat(run.CV, 'f', 6, 64),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// write the runs and the best run as json
+func writeJSON(runs []*Run, best *Run, fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		ModelType string `json:"model_type"`
+		Iteration int    `json:"iter"`
+		Select    string `json:"select"`
+		Best      *Run   `json:"best"`
+		Runs      []*Run `json:"runs"`
+	}{*modelType, *iteration, *selection, best, runs})
+}
+
+func main() {
+	flag.Parse()
+
+	if *input == "" || *heldout == "" {
+		log.Fatal("input_file and heldout_file should be given")
+	}
+	// fail before training if the selection metric or a grid is bad
+	(&Run{}).score()
+	topicGrid := parseTopicNums(*topicNums)
+	alphaGrid, betaGrid := parseFloats(*alphas), parseFloats(*betas)
+
+	dat := load(*input)
+	test := load(*heldout)
+	ref := dat
+	if *reference != "" {
+		ref = load(*reference)
+	}
+
+	var runs []*Run
+	var best *Run
+	var bestModel model.Model
+	for _, k := range topicGrid {
+		for _, alpha := range alphaGrid {
+			for _, beta := range betaGrid {
+				for r := 0; r < *repeats; r += 1 {
+					run := &Run{
+						TopicNum: k,
+						Alpha:    alpha,
+						Beta:     beta,
+						Seed:     *seed + int64(r),
+					}
+					m := train(run, dat, test, ref)
+					log.Infof("k %d alpha %g beta %g seed %d: perplexity %f, umass %f, npmi %f, c_v %f",
+						run.TopicNum, run.Alpha, run.Beta, run.Seed,
+						run.Perplexity, run.UMass, run.NPMI, run.CV)
+					runs = append(runs, run)
+					if best == nil || run.score() > best.score() || math.IsNaN(best.score()) {
+						best, bestModel = run, m
+					}
+				}
+			}
+		}
+	}
+
+	if err := writeCSV(runs, *output+".csv"); err != nil {
+		log.Fatal(err)
+	}
+	if err := writeJSON(runs, best, *output+".json"); err != nil {
+		log.Fatal(err)
+	}
+
+	// save the best model like main does
+	bestModel.SaveTheta(*modelName + ".theta")
+	bestModel.SavePhi(*modelName + ".phi")
+	bestModel.SaveWordTopic(*modelName + ".wt")
+	if s, ok := bestModel.(model.AuthorTopicSaver); ok {
+		s.SaveAuthorTopic(*modelName + ".at")
+	}
+	fmt.Printf("best run k %d alpha %g beta %g seed %d, perplexity %f, umass %f, npmi %f, c_v %f\n",
+		best.TopicNum, best.Alpha, best.Beta, best.Seed,
+		best.Perplexity, best.UMass, best.NPMI, best.CV)
+}
@@ -0,0 +1,208 @@
+// Package coherence scores the top words of topics by how often they
+// co-occur in a reference corpus. The corpus is a bag of words, so the
+// co-occurrence window of all measures is the whole document.
+package coherence
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+// smoothing of joint probabilities of NPMI to avoid log of zero
+const epsilon = 1e-12
+
+// Coherence keeps the top words of each topic and their document
+// frequencies in the reference corpus
+type Coherence struct {
+	Topics [][]uint32 // top words of each topic by descending probability
+
+	index   map[uint32]int // position of word in the counting tables
+	docFreq []uint32       // number of documents containing word
+	coFreq  [][]uint32     // number of documents containing both words
+	docNum  uint32
+}
+
+// get the top n words of each topic of word-topic mixture phi
+func TopWords(phi *sstable.Float32Matrix, n int) [][]uint32 {
+	vocabSize, topicNum := phi.Shape()
+	if n > int(vocabSize) {
+		n = int(vocabSize)
+	}
+	topics := make([][]uint32, topicNum)
+	words := make([]uint32, vocabSize)
+	for k := uint32(0); k < topicNum; k += 1 {
+		for w, _ := range words {
+			words[w] = uint32(w)
+		}
+		sort.SliceStable(words, func(i, j int) bool {
+			return phi.Get(words[i], k) > phi.Get(words[j], k)
+		})
+		topics[k] = append([]uint32(nil), words[:n]...)
+	}
+	return topics
+}
+
+// New counts the document frequencies of the top n words of phi and
+// of their pairs in the reference corpus
+func New(phi *sstable.Float32Matrix, ref *corpus.Corpus, n int) *Coherence {
+	this := &Coherence{
+		Topics: TopWords(phi, n),
+		index:  make(map[uint32]int),
+	}
+	for _, words := range this.Topics {
+		for _, w := range words {
+			if _, ok := this.index[w]; !ok {
+				this.index[w] = len(this.index)
+			}
+		}
+	}
+	this.docFreq = make([]uint32, len(this.index))
+	this.coFreq = make([][]uint32, len(this.index))
+	for i, _ := range this.coFreq {
+		this.coFreq[i] = make([]uint32, len(this.index))
+	}
+
+	// a word may have several entries in a document, e.g. lines of the
+	// same key merged by corpus.Load, so each word is counted once
+	var present []int
+	seen := make([]bool, len(this.index))
+	for _, wcs := range ref.Docs {
+		this.docNum += 1
+		present = present[:0]
+		for _, wc := range wcs {
+			if i, ok := this.index[wc.WordId]; ok && wc.Count > 0 && !seen[i] {
+				seen[i] = true
+				present = append(present, i)
+			}
+		}
+		for a, i := range present {
+			seen[i] = false
+			this.docFreq[i] += 1
+			for _, j := range present[a+1:] {
+				this.coFreq[i][j] += 1
+				this.coFreq[j][i] += 1
+			}
+		}
+	}
+	return this
+}
+
+// compute the normalized pointwise mutual information of two words
+func (this *Coherence) npmi(wi, wj uint32) float64 {
+	i, j := this.index[wi], this.index[wj]
+	n := float64(this.docNum)
+	pij := float64(this.coFreq[i][j])/n + epsilon
+	pi := float64(this.docFreq[i]) / n
+	pj := float64(this.docFreq[j]) / n
+	if pi == 0 || pj == 0 {
+		return 0.0
+	}
+	return math.Log(pij/(pi*pj)) / -math.Log(pij)
+}
+
+// compute the UMass coherence of Mimno et al. (2011) of each topic,
+// the mean of log((D(wi, wj) + 1) / D(wj)) over pairs of top words
+// with wj ranked before wi, words absent from the reference corpus are
+// skipped
+func (this *Coherence) UMass() []float64 {
+	scores := make([]float64, len(this.Topics))
+	for k, words := range this.Topics {
+		sum, pairs := 0.0, 0
+		for a := 1; a < len(words); a += 1 {
+			for b := 0; b < a; b += 1 {
+				i, j := this.index[words[a]], this.index[words[b]]
+				if this.docFreq[j] == 0 {
+					continue
+				}
+				sum += math.Log((float64(this.coFreq[i][j]) + 1.0) /
+					float64(this.docFreq[j]))
+				pairs += 1
+			}
+		}
+		if pairs > 0 {
+			scores[k] = sum / float64(pairs)
+		}
+	}
+	return scores
+}
+
+// compute the NPMI coherence of Bouma (2009) and Aletras and
+// Stevenson (2013) of each topic, the mean of NPMI over pairs of top
+// words
+func (this *Coherence) NPMI() []float64 {
+	scores := make([]float64, len(this.Topics))
+	for k, words := range this.Topics {
+		sum, pairs := 0.0, 0
+		for a := 1; a < len(words); a += 1 {
+			for b := 0; b < a; b += 1 {
+				sum += this.npmi(words[a], words[b])
+				pairs += 1
+			}
+		}
+		if pairs > 0 {
+			scores[k] = sum / float64(pairs)
+		}
+	}
+	return scores
+}
+
+// compute the C_V coherence of Roeder et al. (2015) of each topic,
+// every top word is represented by its vector of NPMI with all top
+// words, and the score is the mean cosine similarity between the
+// vector of each word and the sum of the vectors of all words
+func (this *Coherence) CV() []float64 {
+	scores := make([]float64, len(this.Topics))
+	for k, words := range this.Topics {
+		if len(words) == 0 {
+			continue
+		}
+		vectors := make([][]float64, len(words))
+		total := make([]float64, len(words))
+		for a, wa := range words {
+			vectors[a] = make([]float64, len(words))
+			for b, wb := range words {
+				if a == b {
+					vectors[a][b] = 1.0
+				} else {
+					vectors[a][b] = this.npmi(wa, wb)
+				}
+				total[b] += vectors[a][b]
+			}
+		}
+		sum := 0.0
+		for _, v := range vectors {
+			sum += cosine(v, total)
+		}
+		scores[k] = sum / float64(len(words))
+	}
+	return scores
+}
+
+// compute the cosine similarity of two vectors
+func cosine(x, y []float64) float64 {
+	dot, nx, ny := 0.0, 0.0, 0.0
+	for i, _ := range x {
+		dot += x[i] * y[i]
+		nx += x[i] * x[i]
+		ny += y[i] * y[i]
+	}
+	if nx == 0 || ny == 0 {
+		return 0.0
+	}
+	return dot / math.Sqrt(nx*ny)
+}
+
+// compute the mean of topic scores
+func Mean(scores []float64) float64 {
+	if len(scores) == 0 {
+		return 0.0
+	}
+	sum := 0.0
+	for _, s := range scores {
+		sum += s
+	}
+	return sum / float64(len(scores))
+}
@@ -0,0 +1,69 @@
+package coherence
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestCoherence(t *testing.T) {
+	phi := sstable.NewFloat32Matrix(uint32(4), uint32(2))
+	for w, p := range []float32{0.5, 0.4, 0.05, 0.05} {
+		phi.Set(uint32(w), uint32(0), p)
+		phi.Set(uint32(3-w), uint32(1), p)
+	}
+	assert.Equal(t, [][]uint32{{0, 1}, {3, 2}}, TopWords(phi, 2))
+
+	// words 0 and 1 always appear together, words 2 and 3 never do
+	ref := &corpus.Corpus{}
+	ref.AddDoc(uint32(0), []*corpus.WordCount{{WordId: 0, Count: 1}, {WordId: 1, Count: 2}})
+	ref.AddDoc(uint32(1), []*corpus.WordCount{{WordId: 0, Count: 3}, {WordId: 1, Count: 1}})
+	ref.AddDoc(uint32(2), []*corpus.WordCount{{WordId: 2, Count: 1}})
+	ref.AddDoc(uint32(3), []*corpus.WordCount{{WordId: 3, Count: 1}})
+
+	c := New(phi, ref, 2)
+
+	umass := c.UMass()
+	assert.True(t, math.Abs(umass[0]-math.Log(1.5)) < 1e-9)
+	assert.True(t, math.Abs(umass[1]) < 1e-9)
+
+	npmi := c.NPMI()
+	assert.True(t, math.Abs(npmi[0]-1.0) < 1e-6)
+	assert.True(t, npmi[1] < -0.8)
+
+	cv := c.CV()
+	assert.True(t, math.Abs(cv[0]-1.0) < 1e-6)
+	assert.True(t, cv[1] < 0.1)
+
+	assert.Equal(t, 2.0, Mean([]float64{1.0, 3.0}))
+}
+
+func TestCoherenceRepeatedWords(t *testing.T) {
+	phi := sstable.NewFloat32Matrix(uint32(3), uint32(1))
+	for w, p := range []float32{0.5, 0.3, 0.2} {
+		phi.Set(uint32(w), uint32(0), p)
+	}
+
+	// the entries of words repeated in a document count once
+	ref := &corpus.Corpus{}
+	ref.AddDoc(uint32(0), []*corpus.WordCount{{WordId: 0, Count: 1}, {WordId: 1, Count: 1},
+		{WordId: 0, Count: 2}, {WordId: 1, Count: 1}})
+	ref.AddDoc(uint32(1), []*corpus.WordCount{{WordId: 0, Count: 1}, {WordId: 2, Count: 1}})
+	dedup := &corpus.Corpus{}
+	dedup.AddDoc(uint32(0), []*corpus.WordCount{{WordId: 0, Count: 3}, {WordId: 1, Count: 2}})
+	dedup.AddDoc(uint32(1), []*corpus.WordCount{{WordId: 0, Count: 1}, {WordId: 2, Count: 1}})
+
+	c := New(phi, ref, 3)
+	assert.Equal(t, []uint32{2, 1, 1}, c.docFreq)
+	assert.Equal(t, uint32(1), c.coFreq[0][1])
+	assert.Equal(t, uint32(0), c.coFreq[1][1])
+
+	d := New(phi, dedup, 3)
+	assert.Equal(t, d.UMass(), c.UMass())
+	assert.Equal(t, d.NPMI(), c.NPMI())
+	assert.Equal(t, d.CV(), c.CV())
+}
@@ -0,0 +1,285 @@
+package corpus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	log "github.com/golang/glog"
+)
+
+// the binary corpus file starts with a fixed size header:
+// [magic 8 bytes][version uint32][DocNum uint32][VocabSize uint32]
+// [reserved uint32][Tokens uint64][index offset uint64]
+// followed by one record per document in the order of index:
+// [uvarint key length][key][uvarint number of word counts]
+// [varint word id delta][uvarint count] ... [varint word id delta][uvarint count]
+// and the offset index of DocNum little endian uint64 offsets of the
+// document records. All integers of the header and the index are
+// little endian, word ids are delta encoded in their original order.
+const (
+	binaryMagic      = "GOTMCORP"
+	binaryVersion    = uint32(1)
+	binaryHeaderSize = 40
+)
+
+var (
+	ErrBadBinary    = errors.New("corpus: bad binary corpus")
+	ErrClosedBinary = errors.New("corpus: binary corpus is closed")
+)
+
+// BinaryCorpus gives random access to the documents of a binary corpus
+// file, the file is memory mapped where the platform supports it
+type BinaryCorpus struct {
+	DocNum    uint32
+	VocabSize uint32
+	Tokens    uint64 // total number of tokens
+
+	data  []byte       // content of the file
+	index []byte       // offset index of documents
+	unmap func() error // release data
+}
+
+// whether file fn is a binary corpus
+func IsBinary(fn string) bool {
+	f, err := os.Open(fn)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == binaryMagic
+}
+
+// serialize corpus in the binary format, documents are written with
+// their keys in ascending order of index
+func (this *Corpus) SaveBinary(fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+
+	// errors of the buffered writer are sticky and returned by Flush
+	w := bufio.NewWriterSize(f, 1024*1024)
+	written := uint64(0)
+	buf := make([]byte, binary.MaxVarintLen64)
+	write := func(b []byte) {
+		n, _ := w.Write(b)
+		written += uint64(n)
+	}
+
+	// the header is written once the totals and index are known
+	write(make([]byte, binaryHeaderSize))
+	docIds := this.DocIds()
+	offsets := make([]uint64, 0, len(docIds))
+	tokens := uint64(0)
+	for _, doc := range docIds {
+		offsets = append(offsets, written)
+		key := this.DocKey(doc)
+		write(buf[:binary.PutUvarint(buf, uint64(len(key)))])
+		write([]byte(key))
+		wcs := this.Docs[doc]
+		write(buf[:binary.PutUvarint(buf, uint64(len(wcs)))])
+		prev := int64(0)
+		for _, wc := range wcs {
+			write(buf[:binary.PutVarint(buf, int64(wc.WordId)-prev)])
+			write(buf[:binary.PutUvarint(buf, uint64(wc.Count))])
+			prev = int64(wc.WordId)
+			tokens += uint64(wc.Count)
+		}
+	}
+
+	indexOffset := written
+	for _, off := range offsets {
+		binary.LittleEndian.PutUint64(buf, off)
+		write(buf[:8])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	header := make([]byte, binaryHeaderSize)
+	copy(header, binaryMagic)
+	binary.LittleEndian.PutUint32(header[8:], binaryVersion)
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(docIds)))
+	binary.LittleEndian.PutUint32(header[16:], this.VocabSize)
+	binary.LittleEndian.PutUint64(header[24:], tokens)
+	binary.LittleEndian.PutUint64(header[32:], indexOffset)
+	if _, err := f.WriteAt(header, 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// open binary corpus file fn for random access
+func OpenBinary(fn string) (*BinaryCorpus, error) {
+	data, unmap, err := mapFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	this := &BinaryCorpus{data: data, unmap: unmap}
+	if err := this.parseHeader(); err != nil {
+		this.Close()
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return this, nil
+}
+
+// check the header and locate the offset index
+func (this *BinaryCorpus) parseHeader() error {
+	if len(this.data) < binaryHeaderSize || string(this.data[:8]) != binaryMagic {
+		return ErrBadBinary
+	}
+	if version := binary.LittleEndian.Uint32(this.data[8:]); version != binaryVersion {
+		return fmt.Errorf("unsupported binary corpus version %d", version)
+	}
+	this.DocNum = binary.LittleEndian.Uint32(this.data[12:])
+	this.VocabSize = binary.LittleEndian.Uint32(this.data[16:])
+	this.Tokens = binary.LittleEndian.Uint64(this.data[24:])
+	indexOffset := binary.LittleEndian.Uint64(this.data[32:])
+	size := uint64(len(this.data))
+	if indexOffset < binaryHeaderSize || indexOffset > size ||
+		size-indexOffset != 8*uint64(this.DocNum) {
+		return ErrBadBinary
+	}
+	this.index = this.data[indexOffset:]
+	return nil
+}
+
+// decode the document of index doc, ErrClosedBinary is returned after
+// the corpus is closed
+func (this *BinaryCorpus) Doc(doc uint32) (*Document, error) {
+	if this.data == nil {
+		return nil, ErrClosedBinary
+	}
+	if doc >= this.DocNum {
+		return nil, fmt.Errorf("document %d out of %d documents", doc, this.DocNum)
+	}
+	offset := binary.LittleEndian.Uint64(this.index[8*uint64(doc):])
+	if offset >= uint64(len(this.data)) {
+		return nil, ErrBadBinary
+	}
+	rec := this.data[offset:]
+
+	keyLen, n := binary.Uvarint(rec)
+	if n <= 0 || uint64(len(rec)-n) < keyLen {
+		return nil, ErrBadBinary
+	}
+	rec = rec[n:]
+	key := string(rec[:keyLen])
+	rec = rec[keyLen:]
+
+	num, n := binary.Uvarint(rec)
+	if n <= 0 || num > uint64(len(rec)) {
+		return nil, ErrBadBinary
+	}
+	rec = rec[n:]
+	wcs := make([]*WordCount, num)
+	counts := make([]WordCount, num)
+	prev := int64(0)
+	for i, _ := range wcs {
+		delta, n := binary.Varint(rec)
+		if n <= 0 {
+			return nil, ErrBadBinary
+		}
+		rec = rec[n:]
+		count, n := binary.Uvarint(rec)
+		if n <= 0 {
+			return nil, ErrBadBinary
+		}
+		rec = rec[n:]
+		prev += delta
+		counts[i] = WordCount{WordId: uint32(prev), Count: uint32(count)}
+		wcs[i] = &counts[i]
+	}
+	return &Document{Index: doc, Key: key, Words: wcs}, nil
+}
+
+// get an iterator over the documents in the order of index, the
+// iterator does not own the corpus, which stays open when the iterator
+// is closed
+func (this *BinaryCorpus) Iterator() *BinaryIterator {
+	return &BinaryIterator{dat: this}
+}
+
+// release the mapped file, documents decoded before stay valid
+func (this *BinaryCorpus) Close() error {
+	if this.unmap == nil {
+		return nil
+	}
+	err := this.unmap()
+	this.unmap = nil
+	this.data, this.index = nil, nil
+	return err
+}
+
+// BinaryIterator iterates the documents of a binary corpus
+type BinaryIterator struct {
+	dat   *BinaryCorpus
+	pos   uint32
+	owner bool // whether closing the iterator closes the corpus
+}
+
+func (this *BinaryIterator) Next() (*Document, error) {
+	if this.pos >= this.dat.DocNum {
+		return nil, io.EOF
+	}
+	doc, err := this.dat.Doc(this.pos)
+	if err != nil {
+		return nil, err
+	}
+	this.pos += 1
+	return doc, nil
+}
+
+func (this *BinaryIterator) Reset() error {
+	this.pos = 0
+	return nil
+}
+
+func (this *BinaryIterator) Close() error {
+	if !this.owner {
+		return nil
+	}
+	return this.dat.Close()
+}
+
+// load binary corpus file fn into corpus, the records are decoded
+// into the in-memory documents, so only the text parsing of Load is
+// saved and the corpus takes as much memory as a loaded text file
+func (this *Corpus) LoadBinary(fn string) error {
+	dat, err := OpenBinary(fn)
+	if err != nil {
+		return err
+	}
+	defer dat.Close()
+
+	if this.Docs == nil {
+		this.Docs = make(map[uint32][]*WordCount)
+	}
+	for doc := uint32(0); doc < dat.DocNum; doc += 1 {
+		d, err := dat.Doc(doc)
+		if err != nil {
+			return err
+		}
+		docId := this.addKey(d.Key)
+		this.Docs[docId] = append(this.Docs[docId], d.Words...)
+	}
+	this.DocNum = uint32(len(this.Keys))
+	if dat.VocabSize > this.VocabSize {
+		this.VocabSize = dat.VocabSize
+	}
+
+	log.Infof("number of documents %d", this.DocNum)
+	log.Infof("vocabulary size %d", this.VocabSize)
+	return nil
+}
@@ -0,0 +1,103 @@
+package corpus
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryCorpus(t *testing.T) {
+	text := writeFile(t, "train.txt", "x 7:2 3:1\ny 2:1\nz 100000:4 0:1\n")
+	dat := &Corpus{}
+	dat.Load(text)
+
+	fn := filepath.Join(t.TempDir(), "train.bin")
+	assert.Nil(t, dat.SaveBinary(fn))
+	assert.True(t, IsBinary(fn))
+	assert.False(t, IsBinary(text))
+	format, err := DetectFormat(fn)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatBinary, format)
+
+	bin, err := OpenBinary(fn)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), bin.DocNum)
+	assert.Equal(t, dat.VocabSize, bin.VocabSize)
+	assert.Equal(t, uint64(9), bin.Tokens)
+	doc, err := bin.Doc(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "z", doc.Key)
+	assert.Equal(t, dat.Docs[2], doc.Words)
+	_, err = bin.Doc(3)
+	assert.NotNil(t, err)
+
+	keys, indices := readPass(t, bin.Iterator())
+	assert.Equal(t, []string{"x", "y", "z"}, keys)
+	assert.Equal(t, []uint32{0, 1, 2}, indices)
+
+	// closing an iterator leaves the corpus open for other iterators
+	first := bin.Iterator()
+	assert.Nil(t, first.Close())
+	keys, _ = readPass(t, bin.Iterator())
+	assert.Equal(t, []string{"x", "y", "z"}, keys)
+
+	// a closed corpus returns errors instead of panicking
+	assert.Nil(t, bin.Close())
+	_, err = bin.Doc(0)
+	assert.Equal(t, ErrClosedBinary, err)
+	assert.Nil(t, first.Reset())
+	_, err = first.Next()
+	assert.Equal(t, ErrClosedBinary, err)
+
+	// Load detects the binary file
+	loaded := &Corpus{}
+	loaded.Load(fn)
+	assert.Equal(t, dat.DocNum, loaded.DocNum)
+	assert.Equal(t, dat.VocabSize, loaded.VocabSize)
+	assert.Equal(t, dat.Keys, loaded.Keys)
+	assert.Equal(t, dat.Docs, loaded.Docs)
+
+	it, err := OpenIterator(fn)
+	assert.Nil(t, err)
+	keys, _ = readPass(t, it)
+	assert.Equal(t, []string{"x", "y", "z"}, keys)
+	assert.Nil(t, it.Close())
+}
+
+func TestBadBinaryCorpus(t *testing.T) {
+	dat := &Corpus{}
+	dat.Load(writeFile(t, "train.txt", "x 1:2\n"))
+	fn := filepath.Join(t.TempDir(), "train.bin")
+	assert.Nil(t, dat.SaveBinary(fn))
+
+	content, err := ioutil.ReadFile(fn)
+	assert.Nil(t, err)
+	truncated := writeFile(t, "truncated.bin", string(content[:len(content)-1]))
+	_, err = OpenBinary(truncated)
+	assert.NotNil(t, err)
+	assert.NotNil(t, (&Corpus{}).LoadBinary(truncated))
+}
+
+func TestBadBinaryHeader(t *testing.T) {
+	dat := &Corpus{}
+	dat.Load(writeFile(t, "train.txt", "x 1:2\ny 2:1\n"))
+	fn := filepath.Join(t.TempDir(), "train.bin")
+	assert.Nil(t, dat.SaveBinary(fn))
+	content, err := ioutil.ReadFile(fn)
+	assert.Nil(t, err)
+
+	// doc numbers whose index would start inside the header, or whose
+	// index offset only matches the file size by overflowing
+	size := uint64(len(content))
+	for _, docNum := range []uint64{size / 8, 1000} {
+		bad := make([]byte, len(content))
+		copy(bad, content)
+		binary.LittleEndian.PutUint32(bad[12:], uint32(docNum))
+		binary.LittleEndian.PutUint64(bad[32:], size-8*docNum)
+		_, err := OpenBinary(writeFile(t, "bad.bin", string(bad)))
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,229 @@
+package corpus
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// common english words removed by default
+var englishStopwords = []string{
+	"a", "about", "above", "after", "again", "against", "all", "am", "an",
+	"and", "any", "are", "as", "at", "be", "because", "been", "before",
+	"being", "below", "between", "both", "but", "by", "can", "could", "did",
+	"do", "does", "doing", "down", "during", "each", "few", "for", "from",
+	"further", "had", "has", "have", "having", "he", "her", "here", "hers",
+	"herself", "him", "himself", "his", "how", "i", "if", "in", "into", "is",
+	"it", "its", "itself", "just", "me", "more", "most", "my", "myself", "no",
+	"nor", "not", "now", "of", "off", "on", "once", "only", "or", "other",
+	"our", "ours", "ourselves", "out", "over", "own", "same", "she", "should",
+	"so", "some", "such", "than", "that", "the", "their", "theirs", "them",
+	"themselves", "then", "there", "these", "they", "this", "those", "through",
+	"to", "too", "under", "until", "up", "very", "was", "we", "were", "what",
+	"when", "where", "which", "while", "who", "whom", "why", "will", "with",
+	"would", "you", "your", "yours", "yourself", "yourselves",
+}
+
+// Builder turns raw text into a corpus, every document is tokenized,
+// lowercased and stripped of stopwords, and every new word gets the
+// next word id starting from zero. Documents left without words are
+// skipped, so document ids are dense too, the document keys are kept.
+type Builder struct {
+	Stopwords map[string]bool // words removed from documents
+	MinLength int             // tokens shorter than it are removed
+
+	vocab map[string]uint32 // id of each word
+	words []string          // word of each id
+	docs  [][]*WordCount    // word counts of each document
+	keys  []string          // key of each document
+}
+
+// NewBuilder creates a corpus builder removing english stopwords
+func NewBuilder() *Builder {
+	this := &Builder{
+		Stopwords: make(map[string]bool),
+		MinLength: 1,
+		vocab:     make(map[string]uint32),
+	}
+	for _, w := range englishStopwords {
+		this.Stopwords[w] = true
+	}
+	return this
+}
+
+// split text into lowercased tokens of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// replace the stopwords with the words of file fn, one word per line
+func (this *Builder) LoadStopwords(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	this.Stopwords = make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			this.Stopwords[strings.ToLower(w)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// add text as one document with key, returns false if no word is
+// left after stopwords are removed
+func (this *Builder) AddText(key, text string) bool {
+	counts := make(map[uint32]uint32)
+	for _, token := range Tokenize(text) {
+		if len([]rune(token)) < this.MinLength || this.Stopwords[token] {
+			continue
+		}
+		id, ok := this.vocab[token]
+		if !ok {
+			id = uint32(len(this.words))
+			this.vocab[token] = id
+			this.words = append(this.words, token)
+		}
+		counts[id] += 1
+	}
+	if len(counts) == 0 {
+		return false
+	}
+
+	wcs := make([]*WordCount, 0, len(counts))
+	for id, count := range counts {
+		wcs = append(wcs, &WordCount{WordId: id, Count: count})
+	}
+	sort.Slice(wcs, func(i, j int) bool { return wcs[i].WordId < wcs[j].WordId })
+	this.docs = append(this.docs, wcs)
+	this.keys = append(this.keys, key)
+	return true
+}
+
+// add every line of file fn as one document, the key of a document is
+// its line number counting from zero
+func (this *Builder) AddLines(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 0; scanner.Scan(); line += 1 {
+		this.AddText(strconv.Itoa(line), scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// add every regular file under directory dir as one document, files
+// are added in lexical order of their paths, the key of a document is
+// its path relative to dir with spaces and commas replaced by
+// underscores, and a suffix _n is appended if the key is taken
+func (this *Builder) AddDir(dir string) error {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	used := make(map[string]bool)
+	for _, key := range this.keys {
+		used[key] = true
+	}
+	for _, fn := range files {
+		text, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		key, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		key = strings.Join(strings.FieldsFunc(key, func(r rune) bool {
+			return unicode.IsSpace(r) || r == ','
+		}), "_")
+		for n, base := 1, key; used[key]; n += 1 {
+			key = fmt.Sprintf("%s_%d", base, n)
+		}
+		if this.AddText(key, string(text)) {
+			used[key] = true
+		}
+	}
+	return nil
+}
+
+// get the words of the vocabulary, the index of a word is its id
+func (this *Builder) Vocab() []string {
+	return this.words
+}
+
+// get the corpus of all added documents, documents added with the same
+// key are kept apart
+func (this *Builder) Corpus() *Corpus {
+	dat := &Corpus{
+		VocabSize: uint32(len(this.words)),
+		Docs:      make(map[uint32][]*WordCount),
+	}
+	for doc, wcs := range this.docs {
+		dat.Docs[dat.appendKey(this.keys[doc])] = wcs
+	}
+	dat.DocNum = uint32(len(dat.Keys))
+	return dat
+}
+
+// serialize vocabulary with one word per line, the word of line i
+// (counting from zero) has id i
+func SaveVocab(words []string, fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, word := range words {
+		fmt.Fprintln(w, word)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// load vocabulary written by SaveVocab
+func LoadVocab(fn string) ([]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		words = append(words, strings.TrimSpace(scanner.Text()))
+	}
+	return words, scanner.Err()
+}
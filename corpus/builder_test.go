@@ -0,0 +1,61 @@
+package corpus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	assert.Equal(t, []string{"go", "1", "topic", "models"}, Tokenize("Go-1: Topic models!"))
+
+	b := NewBuilder()
+	assert.True(t, b.AddText("x", "The cat sat on the mat, the cat"))
+	assert.False(t, b.AddText("y", "of the and"))
+	assert.True(t, b.AddText("z", "a mat"))
+
+	assert.Equal(t, []string{"cat", "sat", "mat"}, b.Vocab())
+	dat := b.Corpus()
+	assert.Equal(t, uint32(2), dat.DocNum)
+	assert.Equal(t, uint32(3), dat.VocabSize)
+	assert.Equal(t, []*WordCount{{WordId: 0, Count: 2}, {WordId: 1, Count: 1},
+		{WordId: 2, Count: 1}}, dat.Docs[0])
+	assert.Equal(t, []*WordCount{{WordId: 2, Count: 1}}, dat.Docs[1])
+	assert.Equal(t, []string{"x", "z"}, dat.DocKeys())
+}
+
+func TestBuilderAddDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "a b"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "a b", "x,y.txt"), []byte("cat mat"), 0644))
+
+	b := NewBuilder()
+	assert.Nil(t, b.AddDir(dir))
+	assert.Equal(t, []string{"a_b/x_y.txt"}, b.Corpus().DocKeys())
+}
+
+func TestBuilderKeyCollision(t *testing.T) {
+	dir := t.TempDir()
+	for _, fn := range []string{"a b.txt", "a_b.txt", "a,b.txt"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, fn), []byte("cat "+fn), 0644))
+	}
+
+	b := NewBuilder()
+	assert.Nil(t, b.AddDir(dir))
+	dat := b.Corpus()
+	assert.Equal(t, uint32(3), dat.DocNum)
+	assert.Equal(t, []string{"a_b.txt", "a_b.txt_1", "a_b.txt_2"}, dat.DocKeys())
+	assert.Equal(t, 3, len(dat.Docs))
+
+	// documents added with the same key are not merged
+	b = NewBuilder()
+	b.AddText("x", "cat")
+	b.AddText("x", "mat")
+	dat = b.Corpus()
+	assert.Equal(t, uint32(2), dat.DocNum)
+	assert.Equal(t, []string{"x", "x"}, dat.DocKeys())
+	assert.Equal(t, 2, len(dat.Docs))
+}
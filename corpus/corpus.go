@@ -2,19 +2,31 @@ package corpus
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
 	log "github.com/golang/glog"
 )
 
+// Corpus keeps documents by dense index from 0 to DocNum-1, the
+// external key of a document in the training file is kept in Keys
 type Corpus struct {
 	VocabSize uint32
 	DocNum    uint32
+	AuthorNum uint32
 	Docs      map[uint32][]*WordCount
+	Authors   map[uint32][]uint32 // author ids of each document
+	Keys      []string            // external key of each document
+
+	index map[string]uint32 // document index of each key
 }
 
+var ErrBadDocument = errors.New("corpus: bad document")
+
 type WordCount struct {
 	WordId uint32
 	Count  uint32
@@ -30,9 +42,132 @@ func ExpandWords(wcs []*WordCount) []uint32 {
 	return words
 }
 
-// add one document to corpus with specified docId and word count
-// list, if the specified docId already exists in corpus, the old
-// doc will be overwritted
+// check document key can be written to the comma separated theta
+// file, keys containing commas or line breaks are rejected
+func checkKey(key string) error {
+	if strings.ContainsAny(key, ",\r\n") {
+		return fmt.Errorf("document key %q contains comma or line break", key)
+	}
+	return nil
+}
+
+// parse one line of training file, the line format should be like:
+// [docKey wordId:wordCount wordId:wordCount ... wordId:wordCount]
+// the document key can be any string without spaces and commas,
+// malformed word counts are skipped, ErrBadDocument is returned if the
+// line has no word count at all
+func parseDoc(line string) (string, []*WordCount, error) {
+	vals := strings.Split(line, " ")
+	if len(vals) < 2 {
+		return "", nil, ErrBadDocument
+	}
+	if err := checkKey(vals[0]); err != nil {
+		return "", nil, err
+	}
+
+	var wcs []*WordCount
+	for _, kv := range vals[1:] {
+		wc := strings.Split(kv, ":")
+		if len(wc) != 2 {
+			log.Warningf("bad word count: %s", kv)
+			continue
+		}
+
+		wordId, err := strconv.ParseUint(wc[0], 10, 32)
+		if err != nil {
+			return "", nil, err
+		}
+
+		count, err := strconv.ParseUint(wc[1], 10, 32)
+		if err != nil {
+			return "", nil, err
+		}
+
+		wcs = append(wcs, &WordCount{
+			WordId: uint32(wordId),
+			Count:  uint32(count),
+		})
+	}
+	return vals[0], wcs, nil
+}
+
+// get the index of document key, a new index is assigned if the key
+// is not seen before
+func (this *Corpus) addKey(key string) uint32 {
+	if this.index == nil {
+		this.index = make(map[string]uint32)
+	}
+	if doc, ok := this.index[key]; ok {
+		return doc
+	}
+	doc := uint32(len(this.Keys))
+	this.index[key] = doc
+	this.Keys = append(this.Keys, key)
+	return doc
+}
+
+// add a new document index for key even if the key is seen before,
+// the index of the first document of a key is kept for DocIndex
+func (this *Corpus) appendKey(key string) uint32 {
+	if this.index == nil {
+		this.index = make(map[string]uint32)
+	}
+	doc := uint32(len(this.Keys))
+	if _, ok := this.index[key]; !ok {
+		this.index[key] = doc
+	}
+	this.Keys = append(this.Keys, key)
+	return doc
+}
+
+// get the external key of document, the key of a document added by
+// AddDoc without key is its index
+func (this *Corpus) DocKey(doc uint32) string {
+	if int(doc) < len(this.Keys) {
+		return this.Keys[doc]
+	}
+	return strconv.FormatUint(uint64(doc), 10)
+}
+
+// get the keys of all documents in the order of index
+func (this *Corpus) DocKeys() []string {
+	keys := make([]string, this.DocNum)
+	for doc, _ := range keys {
+		keys[doc] = this.DocKey(uint32(doc))
+	}
+	return keys
+}
+
+// get the index of document key, false is returned if no document
+// has the key
+func (this *Corpus) DocIndex(key string) (uint32, bool) {
+	if this.index != nil {
+		doc, ok := this.index[key]
+		return doc, ok
+	}
+	doc, err := strconv.ParseUint(key, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	_, ok := this.Docs[uint32(doc)]
+	return uint32(doc), ok
+}
+
+// get the ids of documents in ascending order, samplers which should
+// give the same result on every run iterate documents in this order
+// instead of the random order of map iteration
+func (this *Corpus) DocIds() []uint32 {
+	docIds := make([]uint32, 0, len(this.Docs))
+	for doc, _ := range this.Docs {
+		docIds = append(docIds, doc)
+	}
+	sort.Slice(docIds, func(i, j int) bool { return docIds[i] < docIds[j] })
+	return docIds
+}
+
+// add one document to corpus with specified index docId and word
+// count list, if the specified docId already exists in corpus, the
+// old doc will be overwritted
 func (this *Corpus) AddDoc(docId uint32, wcs []*WordCount) {
 	if this.Docs == nil {
 		this.Docs = make(map[uint32][]*WordCount)
@@ -44,10 +179,20 @@ func (this *Corpus) AddDoc(docId uint32, wcs []*WordCount) {
 }
 
 // load training data from file, the file format should be like:
-// [docId wordId:wordCount wordId:wordCount ... wordId:wordCount]
-// the function will panic if docId, wordId and wordCount cannot
-// be parsed to uint32
+// [docKey wordId:wordCount wordId:wordCount ... wordId:wordCount]
+// documents are indexed from 0 in the order their keys first appear,
+// lines with the same key are merged into one document. The function
+// will panic if wordId and wordCount cannot be parsed to uint32.
+// Binary corpus files written by SaveBinary are detected and decoded
+// without parsing text, the documents are held in memory either way
 func (this *Corpus) Load(fn string) {
+	if IsBinary(fn) {
+		if err := this.LoadBinary(fn); err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	f, err := os.Open(fn)
 	if err != nil {
 		panic(err)
@@ -61,47 +206,101 @@ func (this *Corpus) Load(fn string) {
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		doc := scanner.Text()
-		vals := strings.Split(doc, " ")
-		if len(vals) < 2 {
+		key, wcs, err := parseDoc(doc)
+		if err == ErrBadDocument {
 			log.Warningf("bad document: %s", doc)
 			continue
 		}
-
-		docId, err := strconv.ParseUint(vals[0], 10, 32)
 		if err != nil {
 			panic(err)
 		}
 
-		this.DocNum += uint32(1)
+		docId := this.addKey(key)
+		this.DocNum = uint32(len(this.Keys))
 
-		for _, kv := range vals[1:] {
-			wc := strings.Split(kv, ":")
-			if len(wc) != 2 {
-				log.Warningf("bad word count: %s", kv)
-				continue
+		for _, wc := range wcs {
+			this.Docs[docId] = append(this.Docs[docId], wc)
+			if wc.WordId > vocabMaxId {
+				vocabMaxId = wc.WordId
 			}
+		}
+	}
+	this.VocabSize = vocabMaxId + 1
 
-			wordId, err := strconv.ParseUint(wc[0], 10, 32)
-			if err != nil {
-				panic(err)
-			}
+	log.Infof("number of documents %d", this.DocNum)
+	log.Infof("vocabulary size %d", this.VocabSize)
+}
+
+// serialize corpus in the format read by Load, documents are written
+// with their keys in ascending order of index
+func (this *Corpus) Save(fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, doc := range this.DocIds() {
+		fmt.Fprint(w, this.DocKey(doc))
+		for _, wc := range this.Docs[doc] {
+			fmt.Fprintf(w, " %d:%d", wc.WordId, wc.Count)
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-			count, err := strconv.ParseUint(wc[1], 10, 32)
+// load document authors from file, the file format should be like:
+// [docKey authorId authorId ... authorId]
+// documents should be loaded before, lines of unknown keys are skipped
+// and an error is returned if the file cannot be read or authorId
+// cannot be parsed to uint32
+func (this *Corpus) LoadAuthors(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if this.Authors == nil {
+		this.Authors = make(map[uint32][]uint32)
+	}
+	authorMaxId := uint32(0)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		vals := strings.Fields(line)
+		if len(vals) < 2 {
+			log.Warningf("bad document authors: %s", line)
+			continue
+		}
+
+		docId, ok := this.DocIndex(vals[0])
+		if !ok {
+			log.Warningf("authors of unknown document: %s", line)
+			continue
+		}
+
+		for _, val := range vals[1:] {
+			authorId, err := strconv.ParseUint(val, 10, 32)
 			if err != nil {
-				panic(err)
+				return fmt.Errorf("bad author of document %s: %v", vals[0], err)
 			}
-
-			this.Docs[uint32(docId)] = append(this.Docs[uint32(docId)], &WordCount{
-				WordId: uint32(wordId),
-				Count:  uint32(count),
-			})
-			if uint32(wordId) > vocabMaxId {
-				vocabMaxId = uint32(wordId)
+			this.Authors[docId] = append(this.Authors[docId], uint32(authorId))
+			if uint32(authorId) > authorMaxId {
+				authorMaxId = uint32(authorId)
 			}
 		}
 	}
-	this.VocabSize = vocabMaxId + 1
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	this.AuthorNum = authorMaxId + 1
 
-	log.Infof("number of documents %d", this.DocNum)
-	log.Infof("vocabulary size %d", this.VocabSize)
+	log.Infof("number of authors %d", this.AuthorNum)
+	return nil
 }
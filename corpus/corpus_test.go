@@ -0,0 +1,31 @@
+package corpus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDocKey(t *testing.T) {
+	key, wcs, err := parseDoc("user-42 1:2 3:1")
+	assert.Nil(t, err)
+	assert.Equal(t, "user-42", key)
+	assert.Equal(t, 2, len(wcs))
+
+	_, _, err = parseDoc("a,b 1:2")
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrBadDocument, err)
+}
+
+func TestLoadAuthors(t *testing.T) {
+	dat := &Corpus{}
+	dat.Load(writeFile(t, "docs", "x 1:2\ny 2:1\n"))
+
+	assert.Nil(t, dat.LoadAuthors(writeFile(t, "authors", "y 3 1\nz 2\nx 0\n")))
+	assert.Equal(t, uint32(4), dat.AuthorNum)
+	assert.Equal(t, []uint32{0}, dat.Authors[0])
+	assert.Equal(t, []uint32{3, 1}, dat.Authors[1])
+
+	assert.NotNil(t, dat.LoadAuthors(writeFile(t, "bad", "x a\n")))
+	assert.NotNil(t, dat.LoadAuthors(writeFile(t, "missing", "")+".none"))
+}
@@ -0,0 +1,357 @@
+package corpus
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+)
+
+// names of supported input formats
+const (
+	FormatAuto     = "auto"
+	FormatGotm     = "gotm"
+	FormatUCI      = "uci"
+	FormatMM       = "mm"
+	FormatSVMLight = "svmlight"
+	FormatBinary   = "binary"
+)
+
+// number of lines read to detect the format of a text corpus
+const detectLines = 100
+
+// detect the format of corpus file fn, binary files are known by their
+// magic, MatrixMarket files by their banner and UCI docword files by
+// the three header lines of one number. SVMlight files are known by the
+// .svm, .svmlight or .libsvm extension, by qid tokens, by non-integer
+// feature values or by labels repeated across lines, as lines of
+// integer values look the same as gotm lines otherwise
+func DetectFormat(fn string) (string, error) {
+	if IsBinary(fn) {
+		return FormatBinary, nil
+	}
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".svm", ".svmlight", ".libsvm":
+		return FormatSVMLight, nil
+	case ".mtx":
+		return FormatMM, nil
+	}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for len(lines) < detectLines && scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return FormatGotm, nil
+	}
+	if strings.HasPrefix(lines[0], "%%MatrixMarket") {
+		return FormatMM, nil
+	}
+	if len(lines) >= 3 {
+		header := true
+		for _, line := range lines[:3] {
+			if _, err := strconv.ParseUint(line, 10, 64); err != nil {
+				header = false
+				break
+			}
+		}
+		if header {
+			return FormatUCI, nil
+		}
+	}
+	for _, line := range lines {
+		for _, kv := range strings.Fields(line)[1:] {
+			if strings.HasPrefix(kv, "qid:") {
+				return FormatSVMLight, nil
+			}
+			wc := strings.Split(kv, ":")
+			if len(wc) != 2 {
+				continue
+			}
+			if _, err := strconv.ParseUint(wc[1], 10, 32); err != nil {
+				return FormatSVMLight, nil
+			}
+		}
+	}
+	// gotm merges the lines of a key, which is rarely meant for the
+	// first lines of a file but is what labels of SVMlight look like
+	first := make(map[string]bool)
+	for _, line := range lines {
+		key := strings.Fields(line)[0]
+		if first[key] {
+			log.Warningf("first column of %s repeats, read as %s format, set the input format to %s to merge lines of the same key",
+				fn, FormatSVMLight, FormatGotm)
+			return FormatSVMLight, nil
+		}
+		first[key] = true
+	}
+	return FormatGotm, nil
+}
+
+// load corpus file fn of format, the format is detected if it is
+// FormatAuto. Word ids of UCI and MatrixMarket files start from 1 and
+// are shifted to start from 0, so the word of line i of their
+// vocabulary files counting from 0 has id i
+func (this *Corpus) LoadFormat(fn, format string) error {
+	if format == FormatAuto {
+		detected, err := DetectFormat(fn)
+		if err != nil {
+			return err
+		}
+		log.Infof("detected %s format of %s", detected, fn)
+		format = detected
+	}
+	switch format {
+	case FormatGotm:
+		this.Load(fn)
+		return nil
+	case FormatUCI:
+		return this.LoadUCI(fn)
+	case FormatMM:
+		return this.LoadMatrixMarket(fn)
+	case FormatSVMLight:
+		return this.LoadSVMLight(fn)
+	case FormatBinary:
+		return this.LoadBinary(fn)
+	}
+	return fmt.Errorf("unknown corpus format: %s", format)
+}
+
+// add count of word to document of key, word ids above the vocabulary
+// size extend it
+func (this *Corpus) addCount(key string, wordId, count uint32) {
+	if this.Docs == nil {
+		this.Docs = make(map[uint32][]*WordCount)
+	}
+	doc := this.addKey(key)
+	this.DocNum = uint32(len(this.Keys))
+	this.Docs[doc] = append(this.Docs[doc], &WordCount{
+		WordId: wordId,
+		Count:  count,
+	})
+	if wordId >= this.VocabSize {
+		this.VocabSize = wordId + 1
+	}
+}
+
+// convert feature value to word count, values are rounded since term
+// weights such as tf-idf are no counts
+func parseCount(val string) (uint32, error) {
+	if count, err := strconv.ParseUint(val, 10, 32); err == nil {
+		return uint32(count), nil
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 {
+		return 0, fmt.Errorf("negative word count: %s", val)
+	}
+	return uint32(math.Floor(f + 0.5)), nil
+}
+
+// load UCI bag of words file, the file format should be like:
+// [D] [W] [NNZ] on the first three lines followed by
+// [docId wordId count] lines, document ids are kept as keys
+func (this *Corpus) LoadUCI(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var header []uint64
+	lineIdx := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineIdx += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(header) < 3 {
+			val, err := strconv.ParseUint(line, 10, 32)
+			if err != nil {
+				return fmt.Errorf("bad UCI header at line %d: %s", lineIdx, line)
+			}
+			header = append(header, val)
+			continue
+		}
+
+		vals := strings.Fields(line)
+		if len(vals) != 3 {
+			return fmt.Errorf("bad UCI entry at line %d: %s", lineIdx, line)
+		}
+		wordId, err := strconv.ParseUint(vals[1], 10, 32)
+		if err != nil {
+			return err
+		}
+		if wordId == 0 {
+			return fmt.Errorf("UCI word id starts from 1 at line %d: %s", lineIdx, line)
+		}
+		count, err := parseCount(vals[2])
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			this.addCount(vals[0], uint32(wordId-1), count)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(header) < 3 {
+		return fmt.Errorf("UCI header not found in %s", fn)
+	}
+	if uint32(header[1]) > this.VocabSize {
+		this.VocabSize = uint32(header[1])
+	}
+
+	log.Infof("number of documents %d", this.DocNum)
+	log.Infof("vocabulary size %d", this.VocabSize)
+	return nil
+}
+
+// load MatrixMarket coordinate file of the document-term matrix, rows
+// are documents and columns are words. Row numbers are kept as document
+// keys
+func (this *Corpus) LoadMatrixMarket(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sized := false
+	lineIdx := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineIdx += 1
+		line := strings.TrimSpace(scanner.Text())
+		if lineIdx == 1 {
+			banner := strings.Fields(strings.ToLower(line))
+			if len(banner) < 3 || banner[0] != "%%matrixmarket" || banner[2] != "coordinate" {
+				return fmt.Errorf("not a MatrixMarket coordinate file: %s", line)
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "%") {
+			continue
+		}
+
+		vals := strings.Fields(line)
+		if !sized {
+			// [rows cols nnz]
+			if len(vals) != 3 {
+				return fmt.Errorf("bad MatrixMarket size at line %d: %s", lineIdx, line)
+			}
+			cols, err := strconv.ParseUint(vals[1], 10, 32)
+			if err != nil {
+				return err
+			}
+			if uint32(cols) > this.VocabSize {
+				this.VocabSize = uint32(cols)
+			}
+			sized = true
+			continue
+		}
+
+		if len(vals) < 2 {
+			return fmt.Errorf("bad MatrixMarket entry at line %d: %s", lineIdx, line)
+		}
+		col, err := strconv.ParseUint(vals[1], 10, 32)
+		if err != nil {
+			return err
+		}
+		if col == 0 {
+			return fmt.Errorf("MatrixMarket column starts from 1 at line %d: %s", lineIdx, line)
+		}
+		count := uint32(1) // pattern matrix
+		if len(vals) > 2 {
+			if count, err = parseCount(vals[2]); err != nil {
+				return err
+			}
+		}
+		if count > 0 {
+			this.addCount(vals[0], uint32(col-1), count)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	log.Infof("number of documents %d", this.DocNum)
+	log.Infof("vocabulary size %d", this.VocabSize)
+	return nil
+}
+
+// load SVMlight or LibSVM file, the line format should be like:
+// [label featureId:value featureId:value ... # comment]
+// every line is one document whose key is its line number counting
+// from zero, labels are ignored and feature ids are used as word ids
+func (this *Corpus) LoadSVMLight(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineIdx := 0; scanner.Scan(); lineIdx += 1 {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		vals := strings.Fields(line)
+		if len(vals) < 2 {
+			log.Warningf("bad document at line %d: %s", lineIdx, line)
+			continue
+		}
+
+		key := strconv.Itoa(lineIdx)
+		for _, kv := range vals[1:] {
+			fv := strings.Split(kv, ":")
+			if len(fv) != 2 {
+				// qid and other non-feature tokens
+				continue
+			}
+			wordId, err := strconv.ParseUint(fv[0], 10, 32)
+			if err != nil {
+				continue
+			}
+			count, err := parseCount(fv[1])
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				this.addCount(key, uint32(wordId), count)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	log.Infof("number of documents %d", this.DocNum)
+	log.Infof("vocabulary size %d", this.VocabSize)
+	return nil
+}
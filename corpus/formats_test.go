@@ -0,0 +1,80 @@
+package corpus
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	fn := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, ioutil.WriteFile(fn, []byte(content), 0644))
+	return fn
+}
+
+func TestLoadFormats(t *testing.T) {
+	uci := writeFile(t, "docword.txt", "2\n5\n3\n1 2 3\n1 5 1\n7 1 2\n")
+	format, err := DetectFormat(uci)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatUCI, format)
+	dat := &Corpus{}
+	assert.Nil(t, dat.LoadFormat(uci, FormatAuto))
+	assert.Equal(t, uint32(2), dat.DocNum)
+	assert.Equal(t, uint32(5), dat.VocabSize)
+	assert.Equal(t, []string{"1", "7"}, dat.DocKeys())
+	assert.Equal(t, []*WordCount{{WordId: 1, Count: 3}, {WordId: 4, Count: 1}}, dat.Docs[0])
+	assert.Equal(t, []*WordCount{{WordId: 0, Count: 2}}, dat.Docs[1])
+	assert.NotNil(t, (&Corpus{}).LoadUCI(writeFile(t, "docword.txt", "1\n5\n1\n1 0 3\n")))
+
+	mm := writeFile(t, "corpus", "%%MatrixMarket matrix coordinate real general\n% comment\n3 4 2\n3 4 2.0\n1 1 0.6\n")
+	format, err = DetectFormat(mm)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatMM, format)
+	dat = &Corpus{}
+	assert.Nil(t, dat.LoadFormat(mm, FormatAuto))
+	assert.Equal(t, uint32(2), dat.DocNum)
+	assert.Equal(t, uint32(4), dat.VocabSize)
+	assert.Equal(t, []string{"3", "1"}, dat.DocKeys())
+	assert.Equal(t, []*WordCount{{WordId: 3, Count: 2}}, dat.Docs[0])
+	assert.Equal(t, []*WordCount{{WordId: 0, Count: 1}}, dat.Docs[1])
+
+	svm := writeFile(t, "corpus", "+1 qid:2 3:1.5 8:2 # comment\n-1 1:1\n")
+	format, err = DetectFormat(svm)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatSVMLight, format)
+	dat = &Corpus{}
+	assert.Nil(t, dat.LoadFormat(svm, FormatAuto))
+	assert.Equal(t, uint32(2), dat.DocNum)
+	assert.Equal(t, uint32(9), dat.VocabSize)
+	assert.Equal(t, []*WordCount{{WordId: 3, Count: 2}, {WordId: 8, Count: 2}}, dat.Docs[0])
+
+	gotm := writeFile(t, "corpus", "a 1:2 3:1\nb 2:1\n")
+	format, err = DetectFormat(gotm)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatGotm, format)
+
+	assert.NotNil(t, (&Corpus{}).LoadFormat(gotm, "csv"))
+}
+
+func TestDetectSVMLight(t *testing.T) {
+	qid := writeFile(t, "corpus", "3 qid:1 1:2 4:1\n")
+	format, err := DetectFormat(qid)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatSVMLight, format)
+
+	// integer labels and values look like gotm lines but repeat
+	labels := writeFile(t, "corpus", "1 1:2 3:1\n0 2:1\n1 4:3\n")
+	format, err = DetectFormat(labels)
+	assert.Nil(t, err)
+	assert.Equal(t, FormatSVMLight, format)
+	dat := &Corpus{}
+	assert.Nil(t, dat.LoadFormat(labels, FormatAuto))
+	assert.Equal(t, uint32(3), dat.DocNum)
+	assert.Equal(t, []*WordCount{{WordId: 4, Count: 3}}, dat.Docs[2])
+
+	dat = &Corpus{}
+	assert.Nil(t, dat.LoadFormat(labels, FormatGotm))
+	assert.Equal(t, uint32(2), dat.DocNum)
+}
@@ -0,0 +1,278 @@
+package corpus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/golang/glog"
+)
+
+// Document is one document returned by a DocumentIterator
+type Document struct {
+	Index uint32 // dense index of the document within a pass
+	Key   string // external key of the document
+	Words []*WordCount
+}
+
+// DocumentIterator goes over the documents of a corpus in passes, so
+// models can learn from a corpus without holding it in memory. The
+// documents come in the same order with the same indices in every pass.
+// Only models implementing model.StreamTrainer (online variational
+// bayes) train from an iterator, the gibbs, VB and CVB0 samplers still
+// need the whole corpus loaded by Corpus.Load.
+type DocumentIterator interface {
+	// get the next document of the pass, io.EOF is returned when no
+	// document is left
+	Next() (*Document, error)
+	// start a new pass from the first document
+	Reset() error
+	// release the underlying resources
+	Close() error
+}
+
+// MemoryIterator iterates the documents of a loaded corpus in
+// ascending order of index
+type MemoryIterator struct {
+	dat    *Corpus
+	docIds []uint32
+	pos    int
+}
+
+// NewMemoryIterator creates an iterator over corpus dat
+func NewMemoryIterator(dat *Corpus) *MemoryIterator {
+	return &MemoryIterator{
+		dat:    dat,
+		docIds: dat.DocIds(),
+	}
+}
+
+func (this *MemoryIterator) Next() (*Document, error) {
+	if this.pos >= len(this.docIds) {
+		return nil, io.EOF
+	}
+	doc := this.docIds[this.pos]
+	this.pos += 1
+	return &Document{
+		Index: doc,
+		Key:   this.dat.DocKey(doc),
+		Words: this.dat.Docs[doc],
+	}, nil
+}
+
+func (this *MemoryIterator) Reset() error {
+	this.pos = 0
+	return nil
+}
+
+func (this *MemoryIterator) Close() error {
+	return nil
+}
+
+// FileIterator reads the documents of a training file in the format
+// of Corpus.Load line by line, only one document is held in memory.
+// Documents are indexed in the order of lines, lines with the same key
+// are not merged as Load does.
+type FileIterator struct {
+	fn      string
+	file    *os.File
+	scanner *bufio.Scanner
+	index   uint32
+}
+
+// NewFileIterator opens training file fn for iteration
+func NewFileIterator(fn string) (*FileIterator, error) {
+	this := &FileIterator{fn: fn}
+	if err := this.Reset(); err != nil {
+		return nil, err
+	}
+	return this, nil
+}
+
+func (this *FileIterator) Next() (*Document, error) {
+	for this.scanner.Scan() {
+		line := this.scanner.Text()
+		key, wcs, err := parseDoc(line)
+		if err == ErrBadDocument {
+			log.Warningf("bad document: %s", line)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc := &Document{
+			Index: this.index,
+			Key:   key,
+			Words: wcs,
+		}
+		this.index += 1
+		return doc, nil
+	}
+	if err := this.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (this *FileIterator) Reset() error {
+	if this.file == nil {
+		f, err := os.Open(this.fn)
+		if err != nil {
+			return err
+		}
+		this.file = f
+	} else if _, err := this.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	this.scanner = bufio.NewScanner(this.file)
+	this.scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	this.index = 0
+	return nil
+}
+
+func (this *FileIterator) Close() error {
+	if this.file == nil {
+		return nil
+	}
+	err := this.file.Close()
+	this.file = nil
+	return err
+}
+
+// ShardedIterator goes over several iterators one after another, the
+// documents are indexed densely across the shards
+type ShardedIterator struct {
+	shards []DocumentIterator
+	cur    int
+	index  uint32
+}
+
+// NewShardedIterator creates an iterator over shards in order
+func NewShardedIterator(shards ...DocumentIterator) *ShardedIterator {
+	return &ShardedIterator{shards: shards}
+}
+
+func (this *ShardedIterator) Next() (*Document, error) {
+	for this.cur < len(this.shards) {
+		doc, err := this.shards[this.cur].Next()
+		if err == io.EOF {
+			this.cur += 1
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc.Index = this.index
+		this.index += 1
+		return doc, nil
+	}
+	return nil, io.EOF
+}
+
+func (this *ShardedIterator) Reset() error {
+	for _, shard := range this.shards {
+		if err := shard.Reset(); err != nil {
+			return err
+		}
+	}
+	this.cur = 0
+	this.index = 0
+	return nil
+}
+
+func (this *ShardedIterator) Close() error {
+	var firstErr error
+	for _, shard := range this.shards {
+		if err := shard.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// open training file fn for iteration, binary corpus files are
+// iterated without parsing text and closed with the iterator
+func openFile(fn string) (DocumentIterator, error) {
+	if IsBinary(fn) {
+		dat, err := OpenBinary(fn)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryIterator{dat: dat, owner: true}, nil
+	}
+	return NewFileIterator(fn)
+}
+
+// open the training files of spec for iteration, spec is a comma
+// separated list of files or glob patterns, the files matching a
+// pattern are sharded in lexical order
+func OpenIterator(spec string) (DocumentIterator, error) {
+	var files []string
+	for _, pattern := range strings.Split(spec, ",") {
+		matches, err := filepath.Glob(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no training file matches %s", pattern)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	if len(files) == 1 {
+		return openFile(files[0])
+	}
+
+	shards := make([]DocumentIterator, 0, len(files))
+	for _, fn := range files {
+		it, err := openFile(fn)
+		if err != nil {
+			NewShardedIterator(shards...).Close()
+			return nil, err
+		}
+		shards = append(shards, it)
+	}
+	return NewShardedIterator(shards...), nil
+}
+
+// read at most batchSize documents of iterator into a new corpus,
+// documents are indexed from 0 within the minibatch and the VocabSize
+// of the minibatch is the max word id it contains plus one. Documents
+// sharing a key are kept apart like the iterators do. io.EOF is
+// returned when no document is left in the pass
+func ReadBatch(it DocumentIterator, batchSize int) (*Corpus, error) {
+	batch := &Corpus{
+		Docs: make(map[uint32][]*WordCount),
+	}
+	vocabMaxId := uint32(0)
+
+	for int(batch.DocNum) < batchSize {
+		doc, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		docId := batch.appendKey(doc.Key)
+		batch.DocNum = uint32(len(batch.Keys))
+
+		for _, wc := range doc.Words {
+			batch.Docs[docId] = append(batch.Docs[docId], wc)
+			if wc.WordId > vocabMaxId {
+				vocabMaxId = wc.WordId
+			}
+		}
+	}
+	if batch.DocNum == 0 {
+		return nil, io.EOF
+	}
+	batch.VocabSize = vocabMaxId + 1
+
+	return batch, nil
+}
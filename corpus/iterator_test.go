@@ -0,0 +1,85 @@
+package corpus
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// read the keys and indices of one pass
+func readPass(t *testing.T, it DocumentIterator) ([]string, []uint32) {
+	var keys []string
+	var indices []uint32
+	for {
+		doc, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		keys = append(keys, doc.Key)
+		indices = append(indices, doc.Index)
+	}
+	return keys, indices
+}
+
+func TestIterators(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, "a", "x 1:2 3:1\ny 2:1\n")
+	b := writeFile(t, "b", "z 5:1\n")
+
+	fit, err := NewFileIterator(a)
+	assert.Nil(t, err)
+	keys, indices := readPass(t, fit)
+	assert.Equal(t, []string{"x", "y"}, keys)
+	assert.Equal(t, []uint32{0, 1}, indices)
+	assert.Nil(t, fit.Reset())
+	keys, _ = readPass(t, fit)
+	assert.Equal(t, []string{"x", "y"}, keys)
+	assert.Nil(t, fit.Close())
+
+	it, err := OpenIterator(a + "," + b)
+	assert.Nil(t, err)
+	for pass := 0; pass < 2; pass += 1 {
+		assert.Nil(t, it.Reset())
+		keys, indices = readPass(t, it)
+		assert.Equal(t, []string{"x", "y", "z"}, keys)
+		assert.Equal(t, []uint32{0, 1, 2}, indices)
+	}
+
+	assert.Nil(t, it.Reset())
+	batch, err := ReadBatch(it, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), batch.DocNum)
+	assert.Equal(t, uint32(4), batch.VocabSize)
+	batch, err = ReadBatch(it, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"z"}, batch.DocKeys())
+	_, err = ReadBatch(it, 2)
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, it.Close())
+
+	// lines sharing a key are separate documents of a minibatch
+	dup := writeFile(t, "dup", "x 1:2\nx 2:1\n")
+	dit, err := NewFileIterator(dup)
+	assert.Nil(t, err)
+	batch, err = ReadBatch(dit, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), batch.DocNum)
+	assert.Equal(t, []string{"x", "x"}, batch.DocKeys())
+	assert.Equal(t, []*WordCount{{WordId: 2, Count: 1}}, batch.Docs[1])
+	doc, ok := batch.DocIndex("x")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(0), doc)
+	assert.Nil(t, dit.Close())
+
+	_, err = OpenIterator(filepath.Join(dir, "missing-*"))
+	assert.NotNil(t, err)
+
+	dat := &Corpus{}
+	dat.Load(a)
+	keys, indices = readPass(t, NewMemoryIterator(dat))
+	assert.Equal(t, []string{"x", "y"}, keys)
+	assert.Equal(t, []uint32{0, 1}, indices)
+}
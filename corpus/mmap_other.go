@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package corpus
+
+import (
+	"io/ioutil"
+)
+
+// read file fn into memory on platforms without mmap support
+func mapFile(fn string) ([]byte, func() error, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,40 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package corpus
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+
+	log "github.com/golang/glog"
+)
+
+// map file fn into memory read only, the file is read into memory if
+// it cannot be mapped
+func mapFile(fn string) ([]byte, func() error, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	if size == 0 || int64(int(size)) != size {
+		data, err := ioutil.ReadAll(f)
+		return data, func() error { return nil }, err
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		log.Warningf("fail to map %s, read it instead: %v", fn, err)
+		data, err := ioutil.ReadAll(f)
+		return data, func() error { return nil }, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
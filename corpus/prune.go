@@ -0,0 +1,140 @@
+package corpus
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+
+	log "github.com/golang/glog"
+)
+
+// Pruning sets which words and documents are removed from a corpus,
+// zero values disable the corresponding filter
+type Pruning struct {
+	MinDocFreq   uint32  // words in fewer documents are removed
+	MaxDocRatio  float64 // words in a larger ratio of documents are removed
+	TopN         int     // only the most frequent words are kept
+	MinDocLength uint32  // documents with fewer tokens left are removed
+}
+
+// remove words and documents by the pruning options, the remaining
+// words and documents get dense ids in ascending order of their old
+// ids, the mappings from old ids to new ids are returned. Documents
+// keep their keys and documents left without words are always removed.
+func (this *Corpus) Prune(opt *Pruning) (map[uint32]uint32, map[uint32]uint32) {
+	// count document and token frequencies of words
+	docFreq := make(map[uint32]uint32)
+	tokens := make(map[uint32]uint64)
+	for _, wcs := range this.Docs {
+		for _, wc := range wcs {
+			if wc.Count == 0 {
+				continue
+			}
+			docFreq[wc.WordId] += 1
+			tokens[wc.WordId] += uint64(wc.Count)
+		}
+	}
+
+	var words []uint32
+	for w, df := range docFreq {
+		if df < opt.MinDocFreq {
+			continue
+		}
+		if opt.MaxDocRatio > 0 && float64(df) > opt.MaxDocRatio*float64(len(this.Docs)) {
+			continue
+		}
+		words = append(words, w)
+	}
+	if opt.TopN > 0 && len(words) > opt.TopN {
+		sort.Slice(words, func(i, j int) bool {
+			if tokens[words[i]] != tokens[words[j]] {
+				return tokens[words[i]] > tokens[words[j]]
+			}
+			return words[i] < words[j]
+		})
+		words = words[:opt.TopN]
+	}
+	sort.Slice(words, func(i, j int) bool { return words[i] < words[j] })
+	wordMap := make(map[uint32]uint32)
+	for i, w := range words {
+		wordMap[w] = uint32(i)
+	}
+
+	// rewrite documents with new word ids and drop short ones
+	docs := make(map[uint32][]*WordCount)
+	docMap := make(map[uint32]uint32)
+	keys := make([]string, 0, len(this.Docs))
+	for _, doc := range this.DocIds() {
+		var wcs []*WordCount
+		length := uint32(0)
+		for _, wc := range this.Docs[doc] {
+			if id, ok := wordMap[wc.WordId]; ok && wc.Count > 0 {
+				wcs = append(wcs, &WordCount{WordId: id, Count: wc.Count})
+				length += wc.Count
+			}
+		}
+		if len(wcs) == 0 || length < opt.MinDocLength {
+			continue
+		}
+		docMap[doc] = uint32(len(docMap))
+		docs[docMap[doc]] = wcs
+		keys = append(keys, this.DocKey(doc))
+	}
+
+	if this.Authors != nil {
+		authors := make(map[uint32][]uint32)
+		for doc, ids := range this.Authors {
+			if id, ok := docMap[doc]; ok {
+				authors[id] = ids
+			}
+		}
+		this.Authors = authors
+	}
+	log.Infof("pruning keeps %d of %d words and %d of %d documents",
+		len(wordMap), this.VocabSize, len(docMap), this.DocNum)
+	this.Docs = docs
+	this.DocNum = uint32(len(docs))
+	this.Keys, this.index = nil, nil
+	for _, key := range keys {
+		this.addKey(key)
+	}
+	this.VocabSize = uint32(len(wordMap))
+	return wordMap, docMap
+}
+
+// remap vocabulary words whose index is the old word id to the new
+// word ids of mapping
+func RemapVocab(words []string, mapping map[uint32]uint32) []string {
+	remapped := make([]string, len(mapping))
+	for old, id := range mapping {
+		if int(old) < len(words) {
+			remapped[id] = words[old]
+		}
+	}
+	return remapped
+}
+
+// serialize id mapping with one [oldId newId] pair per line in
+// ascending order of new id
+func SaveIdMap(mapping map[uint32]uint32, fn string) error {
+	olds := make([]uint32, 0, len(mapping))
+	for old, _ := range mapping {
+		olds = append(olds, old)
+	}
+	sort.Slice(olds, func(i, j int) bool { return mapping[olds[i]] < mapping[olds[j]] })
+
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, old := range olds {
+		fmt.Fprintf(w, "%d %d\n", old, mapping[old])
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,29 @@
+package corpus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrune(t *testing.T) {
+	dat := &Corpus{VocabSize: uint32(100), DocNum: uint32(4)}
+	// word 7 is in every document, word 99 in only one
+	dat.AddDoc(uint32(0), []*WordCount{{WordId: 7, Count: 1}, {WordId: 10, Count: 3}, {WordId: 99, Count: 1}})
+	dat.AddDoc(uint32(3), []*WordCount{{WordId: 7, Count: 1}, {WordId: 10, Count: 1}, {WordId: 20, Count: 2}})
+	dat.AddDoc(uint32(5), []*WordCount{{WordId: 7, Count: 2}, {WordId: 20, Count: 1}})
+	dat.AddDoc(uint32(9), []*WordCount{{WordId: 7, Count: 1}, {WordId: 10, Count: 1}})
+
+	wordMap, docMap := dat.Prune(&Pruning{MinDocFreq: 2, MaxDocRatio: 0.9, MinDocLength: 2})
+	assert.Equal(t, map[uint32]uint32{10: 0, 20: 1}, wordMap)
+	assert.Equal(t, map[uint32]uint32{0: 0, 3: 1}, docMap)
+	assert.Equal(t, uint32(2), dat.VocabSize)
+	assert.Equal(t, uint32(2), dat.DocNum)
+	assert.Equal(t, []*WordCount{{WordId: 0, Count: 3}}, dat.Docs[0])
+	assert.Equal(t, []*WordCount{{WordId: 0, Count: 1}, {WordId: 1, Count: 2}}, dat.Docs[1])
+	assert.Equal(t, []string{"0", "3"}, dat.DocKeys())
+
+	wordMap, _ = dat.Prune(&Pruning{TopN: 1})
+	assert.Equal(t, map[uint32]uint32{0: 0}, wordMap)
+	assert.Equal(t, []string{"b"}, RemapVocab([]string{"b", "c"}, wordMap))
+}
@@ -0,0 +1,217 @@
+// Package eval estimates the held-out likelihood of documents under
+// the topics of a trained model. The topics are given as the word-topic
+// mixture phi (vocabulary size by topic number) and the document topic
+// prior as one alpha per topic, so every model of gotm can be
+// evaluated the same way.
+package eval
+
+import (
+	"math"
+	"math/rand"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+// the held-out log likelihood and the number of tokens it covers
+type Result struct {
+	LogLikelihood float64
+	Tokens        uint64
+}
+
+// compute the per-token perplexity exp(-loglik / tokens)
+func (this Result) Perplexity() float64 {
+	if this.Tokens == 0 {
+		return math.Inf(1)
+	}
+	return math.Exp(-this.LogLikelihood / float64(this.Tokens))
+}
+
+// Evaluator keeps the topics of the model being evaluated
+type Evaluator struct {
+	Phi      *sstable.Float32Matrix // word-topic mixture
+	Alphas   []float32              // document topic mixture hyperparameter
+	TopicNum uint32
+
+	alphaSum  float32
+	vocabSize uint32
+	rng       *rand.Rand
+}
+
+// NewEvaluator creates an evaluator of topics phi with prior alphas,
+// r is used for all sampling of the estimators
+func NewEvaluator(phi *sstable.Float32Matrix, alphas []float32, r *rand.Rand) *Evaluator {
+	vocabSize, topicNum := phi.Shape()
+	if uint32(len(alphas)) != topicNum {
+		log.Fatalf("%d alphas for %d topics", len(alphas), topicNum)
+	}
+	return &Evaluator{
+		Phi:       phi,
+		Alphas:    alphas,
+		TopicNum:  topicNum,
+		alphaSum:  sstable.Float32VectorSum(alphas),
+		vocabSize: vocabSize,
+		rng:       r,
+	}
+}
+
+// get the tokens of document, words out of the model vocabulary have
+// no probability under the topics and are skipped
+func (this *Evaluator) tokens(wcs []*corpus.WordCount) []uint32 {
+	var words []uint32
+	for _, w := range corpus.ExpandWords(wcs) {
+		if w < this.vocabSize {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// draw a topic of word w with doc-topic counts dt
+func (this *Evaluator) sample(w uint32, dt []uint32, cumsum []float32) uint32 {
+	total := float32(0.0)
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		total += (float32(dt[k]) + this.Alphas[k]) * this.Phi.Get(w, k)
+		cumsum[k] = total
+	}
+	u := this.rng.Float32() * total
+	k := uint32(0)
+	for ; k < this.TopicNum-1; k += 1 {
+		if u < cumsum[k] {
+			break
+		}
+	}
+	return k
+}
+
+// estimate the held-out log likelihood by the left-to-right algorithm
+// of Wallach et al. (2009) "Evaluation methods for topic models", the
+// probability of each token given the previous tokens is averaged over
+// particles, each particle resamples the topics of the previous tokens
+// before predicting the next one
+func (this *Evaluator) LeftToRight(dat *corpus.Corpus, particles int) Result {
+	result := Result{}
+	cumsum := make([]float32, this.TopicNum)
+	dt := make([]uint32, this.TopicNum)
+	oov := uint64(0)
+
+	for _, doc := range dat.DocIds() {
+		words := this.tokens(dat.Docs[doc])
+		oov += uint64(len(corpus.ExpandWords(dat.Docs[doc])) - len(words))
+		if len(words) == 0 {
+			continue
+		}
+		topics := make([][]uint32, particles)
+		for r, _ := range topics {
+			topics[r] = make([]uint32, len(words))
+		}
+
+		for n, w := range words {
+			prob := 0.0
+			for r := 0; r < particles; r += 1 {
+				z := topics[r]
+				for k, _ := range dt {
+					dt[k] = 0
+				}
+				for _, k := range z[:n] {
+					dt[k] += 1
+				}
+				// resample the topics of the previous tokens
+				for i := 0; i < n; i += 1 {
+					dt[z[i]] -= 1
+					z[i] = this.sample(words[i], dt, cumsum)
+					dt[z[i]] += 1
+				}
+				// predict the current token
+				p := 0.0
+				for k := uint32(0); k < this.TopicNum; k += 1 {
+					p += float64(this.Phi.Get(w, k)) *
+						(float64(dt[k]) + float64(this.Alphas[k])) /
+						(float64(n) + float64(this.alphaSum))
+				}
+				prob += p
+				z[n] = this.sample(w, dt, cumsum)
+			}
+			result.LogLikelihood += math.Log(prob / float64(particles))
+		}
+		result.Tokens += uint64(len(words))
+	}
+
+	if oov > 0 {
+		log.Warningf("%d tokens out of vocabulary are skipped", oov)
+	}
+	return result
+}
+
+// estimate the held-out log likelihood by document completion, the
+// tokens of each document are split alternately into an observed half
+// and a held-out half, theta of the document is estimated from the
+// observed half by gibbs sampling with phi held fixed and averaged
+// over the last half of iter sweeps, then the held-out half is scored
+// by sum_k theta_dk * phi_wk
+func (this *Evaluator) DocumentCompletion(dat *corpus.Corpus, iter int) Result {
+	result := Result{}
+	cumsum := make([]float32, this.TopicNum)
+	dt := make([]uint32, this.TopicNum)
+	theta := make([]float64, this.TopicNum)
+	if iter < 1 {
+		iter = 1
+	}
+	burnin := iter / 2
+
+	for _, doc := range dat.DocIds() {
+		words := this.tokens(dat.Docs[doc])
+		var observed, heldout []uint32
+		for i, w := range words {
+			if i%2 == 0 {
+				observed = append(observed, w)
+			} else {
+				heldout = append(heldout, w)
+			}
+		}
+		if len(heldout) == 0 {
+			continue
+		}
+
+		// randomly init topics of the observed half
+		for k, _ := range dt {
+			dt[k] = 0
+			theta[k] = 0.0
+		}
+		z := make([]uint32, len(observed))
+		for i, _ := range z {
+			z[i] = uint32(this.rng.Int31n(int32(this.TopicNum)))
+			dt[z[i]] += 1
+		}
+
+		samples := 0
+		for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+			for i, w := range observed {
+				dt[z[i]] -= 1
+				z[i] = this.sample(w, dt, cumsum)
+				dt[z[i]] += 1
+			}
+			if iterIdx < burnin {
+				continue
+			}
+			for k := uint32(0); k < this.TopicNum; k += 1 {
+				theta[k] += (float64(dt[k]) + float64(this.Alphas[k])) /
+					(float64(len(observed)) + float64(this.alphaSum))
+			}
+			samples += 1
+		}
+
+		for _, w := range heldout {
+			p := 0.0
+			for k := uint32(0); k < this.TopicNum; k += 1 {
+				p += theta[k] / float64(samples) * float64(this.Phi.Get(w, k))
+			}
+			result.LogLikelihood += math.Log(p)
+		}
+		result.Tokens += uint64(len(heldout))
+	}
+
+	return result
+}
@@ -0,0 +1,35 @@
+package eval
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestUniformTopicsPerplexity(t *testing.T) {
+	// every topic is uniform over 5 words, so every token has
+	// probability 1/5 whatever its topic is
+	phi := sstable.NewFloat32Matrix(uint32(5), uint32(2))
+	for w := uint32(0); w < 5; w += 1 {
+		phi.Set(w, uint32(0), float32(0.2))
+		phi.Set(w, uint32(1), float32(0.2))
+	}
+	dat := &corpus.Corpus{VocabSize: uint32(7), DocNum: uint32(2)}
+	dat.AddDoc(uint32(0), []*corpus.WordCount{{WordId: 0, Count: 3}, {WordId: 4, Count: 1}})
+	dat.AddDoc(uint32(1), []*corpus.WordCount{{WordId: 2, Count: 2}, {WordId: 6, Count: 1}})
+
+	e := NewEvaluator(phi, []float32{0.1, 0.3}, rand.New(rand.NewSource(1)))
+
+	l2r := e.LeftToRight(dat, 5)
+	assert.Equal(t, uint64(6), l2r.Tokens)
+	assert.True(t, math.Abs(l2r.Perplexity()-5.0) < 1e-4)
+
+	dc := e.DocumentCompletion(dat, 10)
+	assert.Equal(t, uint64(3), dc.Tokens)
+	assert.True(t, math.Abs(dc.Perplexity()-5.0) < 1e-4)
+}
@@ -2,15 +2,22 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"math/rand"
+	"time"
 
 	log "github.com/golang/glog"
 
+	"github.com/bobonovski/gotm/coherence"
 	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/eval"
 	"github.com/bobonovski/gotm/model"
 )
 
 var (
 	input     = flag.String("input_file", "", "input training file")
+	inputFmt  = flag.String("input_format", "auto", "format of input file: gotm, uci, mm, svmlight, binary or auto")
+	authors   = flag.String("author_file", "", "input document author file")
 	modelType = flag.String("model_type", "lda", "model type")
 	alpha     = flag.Float64("alpha", 0.01, "document-topic mixture hyperparameter")
 	beta      = flag.Float64("beta", 0.01, "topic-word mixture hyperparameter")
@@ -18,32 +25,196 @@ var (
 	iteration = flag.Int("iter", 10, "number of iteration")
 	modelName = flag.String("model_file", "lda_model", "input/output model name")
 	infer     = flag.Bool("infer", false, "whether do inference on input file")
+	hyper     = flag.String("hyper_params", "", "extra model hyperparameters, e.g. discount=0.5,concentration=10")
+	stream    = flag.Bool("stream", false, "whether train on minibatches read from input files without loading them, input_file can be comma separated files or glob patterns. Only online lda supports streaming, other models load the whole corpus")
+	workers   = flag.Int("workers", 0, "number of sampling goroutines, 0 means the model default")
+	evaluate  = flag.Bool("eval", false, "whether compute held-out perplexity of input file")
+	particles = flag.Int("particles", 20, "number of particles of left-to-right evaluation")
+	reference = flag.String("reference_file", "", "reference corpus of topic coherence evaluation")
+	topN      = flag.Int("top_n", 10, "number of top words of topic coherence evaluation")
+	ckptIter  = flag.Int("checkpoint_interval", 0, "iterations between checkpoints written to <model_file>.ckpt, 0 disables")
+	resume    = flag.Bool("resume", false, "whether continue training from <model_file>.ckpt")
+	seed      = flag.Int64("seed", 0, "seed of random numbers, 0 means seeded by current time")
+	metrics   = flag.String("metrics_file", "", "file of training metrics, csv if it ends with .csv and json lines otherwise")
+	metricsIt = flag.Int("metrics_interval", 10, "iterations between training metrics records")
+	burnin    = flag.Int("burnin", 0, "iterations before phi and theta are sampled for averaging")
+	thin      = flag.Int("thin", 0, "iterations between samples of phi and theta averaged after burn-in, 0 disables")
+	earlyStop = flag.Float64("early_stop", 0, "stop training when relative likelihood improvement is below it, 0 disables")
 )
 
+// train model for iter passes of minibatches over the input files, the
+// input can be a comma separated list of files or glob patterns whose
+// files are read one after another
+func trainStream(m model.Model) {
+	s, ok := m.(model.StreamTrainer)
+	if !ok {
+		log.Fatalf("model %s does not support streaming", *modelType)
+	}
+	it, err := corpus.OpenIterator(*input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer it.Close()
+
+	for iterIdx := 0; iterIdx < *iteration; iterIdx += 1 {
+		if err := it.Reset(); err != nil {
+			log.Fatal(err)
+		}
+		if err := s.TrainStream(it); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// compute held-out perplexity of data under the loaded model, and
+// the topic coherence if reference corpus is given
+func evaluateModel(m model.Model, data *corpus.Corpus) {
+	phi := m.Phi()
+	_, k := phi.Shape()
+	alphas := model.DocTopicPrior(m, k, float32(*alpha))
+	evalSeed := *seed
+	if evalSeed == 0 {
+		evalSeed = time.Now().UnixNano()
+	}
+	e := eval.NewEvaluator(phi, alphas, rand.New(rand.NewSource(evalSeed)))
+
+	l2r := e.LeftToRight(data, *particles)
+	fmt.Printf("left-to-right perplexity %f over %d tokens\n", l2r.Perplexity(), l2r.Tokens)
+	dc := e.DocumentCompletion(data, *iteration)
+	fmt.Printf("document completion perplexity %f over %d tokens\n", dc.Perplexity(), dc.Tokens)
+
+	if *reference == "" {
+		return
+	}
+	ref := &corpus.Corpus{}
+	if err := ref.LoadFormat(*reference, corpus.FormatAuto); err != nil {
+		log.Fatal(err)
+	}
+	c := coherence.New(phi, ref, *topN)
+	umass, npmi, cv := c.UMass(), c.NPMI(), c.CV()
+	for k, words := range c.Topics {
+		fmt.Printf("topic %d umass %f npmi %f c_v %f words %v\n",
+			k, umass[k], npmi[k], cv[k], words)
+	}
+	fmt.Printf("average coherence umass %f npmi %f c_v %f\n",
+		coherence.Mean(umass), coherence.Mean(npmi), coherence.Mean(cv))
+}
+
 func main() {
 	flag.Parse()
 
-	// load documents for training or inference
-	data := &corpus.Corpus{}
-	data.Load(*input)
-
 	// init model
 	ctor, err := model.GetModel(*modelType)
 	if err != nil {
 		log.Fatal(err)
 	}
 	m := ctor(uint32(*topicNum), float32(*alpha), float32(*beta))
+	if err := model.SetHyperParams(m, *hyper); err != nil {
+		log.Fatal(err)
+	}
+	if *workers > 0 {
+		s, ok := m.(model.WorkerSetter)
+		if !ok {
+			log.Fatalf("model %s does not support multiple workers", *modelType)
+		}
+		if err := s.SetWorkers(*workers); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *seed != 0 {
+		s, ok := m.(model.Seeder)
+		if !ok {
+			log.Fatalf("model %s does not support seeding", *modelType)
+		}
+		s.Seed(*seed)
+	}
+
+	if *stream && *infer == false && *evaluate == false {
+		if *inputFmt != corpus.FormatAuto && *inputFmt != corpus.FormatGotm &&
+			*inputFmt != corpus.FormatBinary {
+			log.Fatalf("streaming only reads gotm and binary formats, not %s", *inputFmt)
+		}
+		log.Infof("training for new %s model on stream", *modelType)
+		trainStream(m)
+		// save word-topic distribution
+		m.SavePhi(*modelName + ".phi")
+		// save word-topic matrix
+		m.SaveWordTopic(*modelName + ".wt")
+		return
+	}
 
-	if *infer == false {
-		log.Infof("training for new %s model", *modelType)
-		// train model
-		m.Train(data, *iteration)
+	// load documents for training or inference
+	data := &corpus.Corpus{}
+	if err := data.LoadFormat(*input, *inputFmt); err != nil {
+		log.Fatal(err)
+	}
+	if *authors != "" {
+		if err := data.LoadAuthors(*authors); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *evaluate {
+		log.Infof("evaluate %s model on held-out docs", *modelType)
+		// load word-topic matrix
+		if err := m.LoadWordTopic(*modelName + ".wt"); err != nil {
+			log.Fatal(err)
+		}
+		evaluateModel(m, data)
+	} else if *infer == false {
+		if *ckptIter > 0 || *resume {
+			c, ok := m.(model.Checkpointer)
+			if !ok {
+				log.Fatalf("model %s does not support checkpoints", *modelType)
+			}
+			c.SetCheckpoint(*modelName+".ckpt", *ckptIter)
+		}
+		if *metrics != "" || *earlyStop > 0 {
+			s, ok := m.(model.MonitorSetter)
+			if !ok {
+				log.Fatalf("model %s does not support training monitor", *modelType)
+			}
+			monitor, err := model.NewMonitor(*metrics, *metricsIt, *earlyStop)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer monitor.Close()
+			s.SetMonitor(monitor)
+		}
+		if *thin > 0 {
+			s, ok := m.(model.SampleAverager)
+			if !ok {
+				log.Fatalf("model %s does not support posterior averaging", *modelType)
+			}
+			if *burnin >= *iteration {
+				log.Fatalf("burnin %d should be less than iter %d, no sample is taken otherwise",
+					*burnin, *iteration)
+			}
+			if err := s.SetAveraging(*burnin, *thin); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *resume {
+			log.Infof("resume training of %s model", *modelType)
+			err := m.(model.Checkpointer).Resume(data, *modelName+".ckpt", *iteration)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			log.Infof("training for new %s model", *modelType)
+			// train model
+			m.Train(data, *iteration)
+		}
 		// save document-topic distribution
 		m.SaveTheta(*modelName + ".theta")
 		// save word-topic distribution
 		m.SavePhi(*modelName + ".phi")
 		// save word-topic matrix
 		m.SaveWordTopic(*modelName + ".wt")
+		// save author-topic distribution
+		if s, ok := m.(model.AuthorTopicSaver); ok {
+			s.SaveAuthorTopic(*modelName + ".at")
+		}
 	} else {
 		log.Infof("infer for new docs")
 		// load word-topic matrix
@@ -0,0 +1,243 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("adlda", NewADLDA)
+}
+
+// ADLDA is the approximate distributed LDA of Newman et al. (2009).
+// Documents are split across Workers goroutines, each worker runs the
+// collapsed gibbs sampler on its own documents against the word-topic
+// counts of the last sweep plus its local deltas, and the deltas of all
+// workers are merged into Wt and Wts at the end of every sweep. A
+// document belongs to one worker, so Dt and the assignment store are
+// updated in place.
+type ADLDA struct {
+	*ldaBase
+	Workers int // number of sampling goroutines
+
+	Z *sstable.Assignment // doc-word-topic assignment store
+}
+
+// adWorker is the local state of one sampling goroutine
+type adWorker struct {
+	docs     []uint32           // documents of the worker
+	delta    map[uint32][]int32 // word-topic count deltas of the sweep
+	sumDelta []int32            // topic count deltas of the sweep
+	rng      *rand.Rand
+}
+
+// NewADLDA creates a parallel LDA instance with one worker per cpu,
+// the number of workers can be changed by SetWorkers
+func NewADLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &ADLDA{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+		Workers: runtime.NumCPU(),
+	}
+}
+
+// set the number of sampling goroutines
+func (this *ADLDA) SetWorkers(n int) error {
+	if n < 1 {
+		return fmt.Errorf("number of workers should be positive: %d", n)
+	}
+	this.Workers = n
+	return nil
+}
+
+// randomly assign topic to word, Wt and Wts are only touched if
+// updateWords is true
+func (this *ADLDA) Init(updateWords bool) {
+	this.Z = sstable.NewAssignment()
+	for _, doc := range this.Data.DocIds() {
+		words := corpus.ExpandWords(this.Data.Docs[doc])
+		this.Z.Alloc(doc, uint32(len(words)))
+		for i, w := range words {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			this.Dt.Incr(doc, k, uint32(1))
+			if updateWords {
+				this.Wt.Incr(w, k, uint32(1))
+				this.Wts.Incr(k, uint32(0), uint32(1))
+			}
+			this.Z.Set(doc, uint32(i), k)
+		}
+	}
+}
+
+// split documents across workers in ascending order of id
+func (this *ADLDA) split() []*adWorker {
+	workers := make([]*adWorker, this.Workers)
+	for i, _ := range workers {
+		workers[i] = &adWorker{
+			delta:    make(map[uint32][]int32),
+			sumDelta: make([]int32, this.TopicNum),
+			rng:      rand.New(rand.NewSource(this.rng.Int63())),
+		}
+	}
+	for i, doc := range this.Data.DocIds() {
+		worker := workers[i%this.Workers]
+		worker.docs = append(worker.docs, doc)
+	}
+	return workers
+}
+
+// sample the topics of the documents of worker once, the word-topic
+// counts seen by the worker are Wt plus its deltas, deltas are only
+// kept if updateWords is true
+func (this *ADLDA) sample(worker *adWorker, updateWords bool) {
+	vocabSize, _ := this.Wt.Shape()
+	betaSum := this.Beta * float32(vocabSize)
+	cumsum := make([]float32, this.TopicNum)
+	zero := make([]int32, this.TopicNum)
+
+	for _, doc := range worker.docs {
+		for i, w := range corpus.ExpandWords(this.Data.Docs[doc]) {
+			k := this.Z.Get(doc, uint32(i))
+			delta := zero
+			if updateWords {
+				delta = worker.delta[w]
+				if delta == nil {
+					delta = make([]int32, this.TopicNum)
+					worker.delta[w] = delta
+				}
+				delta[k] -= 1
+				worker.sumDelta[k] -= 1
+			}
+			this.Dt.Decr(doc, k, uint32(1))
+
+			// resample the topic
+			total := float32(0.0)
+			for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+				wordCount := delta[kidx]
+				if w < vocabSize {
+					wordCount += int32(this.Wt.Get(w, kidx))
+				}
+				docPart := this.Alpha + float32(this.Dt.Get(doc, kidx))
+				wordPart := (this.Beta + float32(wordCount)) /
+					(float32(int32(this.Wts.Get(kidx, uint32(0)))+worker.sumDelta[kidx]) +
+						betaSum)
+				total += docPart * wordPart
+				cumsum[kidx] = total
+			}
+			u := worker.rng.Float32() * total
+			for k = 0; k < this.TopicNum-1; k += 1 {
+				if u < cumsum[k] {
+					break
+				}
+			}
+
+			if updateWords {
+				delta[k] += 1
+				worker.sumDelta[k] += 1
+			}
+			this.Dt.Incr(doc, k, uint32(1))
+			this.Z.Set(doc, uint32(i), k)
+		}
+	}
+}
+
+// merge the deltas of worker into Wt and Wts and clear them
+func (this *ADLDA) merge(worker *adWorker) {
+	for w, delta := range worker.delta {
+		for k, d := range delta {
+			if d > 0 {
+				this.Wt.Incr(w, uint32(k), uint32(d))
+			} else if d < 0 {
+				this.Wt.Decr(w, uint32(k), uint32(-d))
+			}
+			delta[k] = 0
+		}
+	}
+	for k, d := range worker.sumDelta {
+		if d > 0 {
+			this.Wts.Incr(uint32(k), uint32(0), uint32(d))
+		} else if d < 0 {
+			this.Wts.Decr(uint32(k), uint32(0), uint32(-d))
+		}
+		worker.sumDelta[k] = 0
+	}
+}
+
+// run the workers in parallel for iter sweeps
+func (this *ADLDA) run(iter int, updateWords bool) {
+	workers := this.split()
+	var wg sync.WaitGroup
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		for _, worker := range workers {
+			wg.Add(1)
+			go func(worker *adWorker) {
+				defer wg.Done()
+				this.sample(worker, updateWords)
+			}(worker)
+		}
+		wg.Wait()
+
+		// the counts are atomic so the merges can run in parallel
+		if updateWords {
+			for _, worker := range workers {
+				wg.Add(1)
+				go func(worker *adWorker) {
+					defer wg.Done()
+					this.merge(worker)
+				}(worker)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+func (this *ADLDA) ResampleTopics(iter int) {
+	this.run(iter, true)
+}
+
+func (this *ADLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	// create sstables
+	this.Wt = sstable.NewUint32Matrix(dat.VocabSize, this.TopicNum)
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init(true)
+
+	log.Infof("sampling with %d workers", this.Workers)
+	this.ResampleTopics(iter)
+}
+
+// infer topics on new documents, the loaded word-topic counts are
+// held fixed so the workers need no merge
+func (this *ADLDA) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.Wts == nil {
+		log.Fatal("Wt or Wts is not initialized, maybe model is not loaded")
+	}
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Data = dat
+
+	// randomly init doc-topic table
+	this.Init(false)
+
+	this.run(iter, false)
+}
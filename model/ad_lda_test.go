@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestADLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewADLDA(uint32(4), float32(0.1), float32(0.01)).(*ADLDA)
+	m.Seed(1)
+	m.SetWorkers(3)
+	m.Train(dat, 10)
+
+	assertCounts(t, dat, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertAssignments(t, dat, m.TopicNum, m.Wt, m.Dt, m.Z.Get)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	// the workers need no merge in inference
+	wt := sstable.NewUint32Matrix(dat.VocabSize, m.TopicNum)
+	for w := uint32(0); w < dat.VocabSize; w += 1 {
+		for k := uint32(0); k < m.TopicNum; k += 1 {
+			wt.Set(w, k, m.Wt.Get(w, k))
+		}
+	}
+	m.Infer(newHeldOutCorpus(), 5)
+	assert.Equal(t, wt, m.Wt)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestADLDASplit(t *testing.T) {
+	m := NewADLDA(uint32(4), float32(0.1), float32(0.01)).(*ADLDA)
+	m.Data = newTestCorpus()
+	for _, n := range []int{1, 3, 50} {
+		m.SetWorkers(n)
+		seen := make(map[uint32]int)
+		for _, worker := range m.split() {
+			for _, doc := range worker.docs {
+				seen[doc] += 1
+			}
+		}
+		assert.Equal(t, int(m.Data.DocNum), len(seen))
+		for _, count := range seen {
+			assert.Equal(t, 1, count)
+		}
+	}
+	assert.NotNil(t, m.SetWorkers(0))
+}
+
+func TestADLDAPosterior(t *testing.T) {
+	// a document is sampled by one worker against its own deltas, so
+	// the sampler is exact within a document
+	dat, wt, wts := newTwoTokenCorpus()
+	m := NewADLDA(uint32(3), float32(0.5), float32(0.1)).(*ADLDA)
+	m.Seed(1)
+	m.SetWorkers(2)
+	posterior := twoTokenPosterior(m.Alpha, m.Beta, wt, wts)
+	m.Wt, m.Wts = wt, wts
+	m.Dt = sstable.NewUint32Matrix(dat.DocNum, m.TopicNum)
+	m.Data = dat
+	m.Init(true)
+
+	assertTwoTokenPosterior(t, posterior, 40000, func() { m.ResampleTopics(1) },
+		func() map[sstable.DocWord]uint32 {
+			return map[sstable.DocWord]uint32{
+				sstable.DocWord{DocId: 0, WordIdx: 0}: m.Z.Get(0, 0),
+				sstable.DocWord{DocId: 0, WordIdx: 1}: m.Z.Get(0, 1),
+			}
+		})
+}
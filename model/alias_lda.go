@@ -0,0 +1,196 @@
+package model
+
+import (
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("aliaslda", NewAliasLDA)
+}
+
+// AliasLDA splits the collapsed conditional of a word into a sparse
+// document part n_dk*(n_wk+beta)/(n_k+beta*V) which is computed exactly
+// in O(k_d), and a dense smoothing part alpha*(n_wk+beta)/(n_k+beta*V)
+// which is drawn from a stale per-word alias table. The mixture is used
+// as Metropolis-Hastings proposal and the alias table of a word is only
+// rebuilt after TopicNum samples have been drawn from it, so the cost
+// of building it is amortized to O(1) per token.
+type AliasLDA struct {
+	*ldaBase
+	MHSteps uint32             // number of metropolis hastings steps per token
+	Dtm     *sstable.SortedMap // nonzero doc-topic counts
+
+	tables []*sstable.AliasTable // stale word alias tables
+	draws  []uint32              // samples drawn since table was built
+}
+
+// NewAliasLDA creates an alias lda instance with metropolis hastings
+// sampler whose amortized cost per token is O(k_d)
+func NewAliasLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &AliasLDA{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+		MHSteps: uint32(2),
+	}
+}
+
+// the current topic-word part (n_wk+beta)/(n_k+beta*V) of the conditional
+func (this *AliasLDA) wordPart(w, k uint32) float32 {
+	return (this.Beta + float32(this.Wt.Get(w, k))) /
+		(float32(this.Wts.Get(k, uint32(0))) +
+			this.Beta*float32(this.Data.VocabSize))
+}
+
+// get the alias table of word w, the table is rebuilt from current
+// counts if it is used for the first time or it has gone stale
+func (this *AliasLDA) aliasTable(w uint32, weights []float32) *sstable.AliasTable {
+	if this.tables[w] == nil {
+		this.tables[w] = sstable.NewAliasTable(this.TopicNum)
+		this.draws[w] = this.TopicNum
+	}
+	if this.draws[w] >= this.TopicNum {
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			weights[k] = this.Alpha * this.wordPart(w, k)
+		}
+		this.tables[w].Build(weights)
+		this.draws[w] = 0
+	}
+	return this.tables[w]
+}
+
+// init doc-topic map and alias tables after the count tables are
+// randomly initialized
+func (this *AliasLDA) initTables() {
+	this.Dtm = sstable.NewSortedMap(this.TopicNum)
+	for doc, _ := range this.Data.Docs {
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			if cnt := this.Dt.Get(doc, k); cnt > 0 {
+				this.Dtm.Incr(doc, k, cnt)
+			}
+		}
+	}
+	this.tables = make([]*sstable.AliasTable, this.Data.VocabSize)
+	this.draws = make([]uint32, this.Data.VocabSize)
+}
+
+func (this *AliasLDA) ResampleTopics(iter int) {
+	dw := sstable.DocWord{}
+	weights := make([]float32, this.TopicNum)
+	// cache of the sparse document part of current word
+	docPart := make([]float32, this.TopicNum)
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			for i, w := range corpus.ExpandWords(wcs) {
+				// get the current topic of word w
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+
+				// decrease corresponding sufficient statistics
+				this.Wt.Decr(w, k, uint32(1))
+				this.Dt.Decr(doc, k, uint32(1))
+				this.Dtm.Decr(doc, k, uint32(1))
+				this.Wts.Decr(k, uint32(0), uint32(1))
+
+				// compute the sparse document part
+				docSum := float32(0.0)
+				for idx, _ := range this.Dtm.Data[doc] {
+					tid, count := this.Dtm.Get(doc, idx)
+					docPart[tid] = float32(count) * this.wordPart(w, tid)
+					docSum += docPart[tid]
+				}
+				table := this.aliasTable(w, weights)
+
+				// metropolis hastings chain starting from current topic
+				s := k
+				for step := uint32(0); step < this.MHSteps; step += 1 {
+					var t uint32
+					u := this.rng.Float32() * (docSum + table.Sum)
+					if u < docSum { // sparse document part
+						cumsum := float32(0.0)
+						for idx, _ := range this.Dtm.Data[doc] {
+							tid, _ := this.Dtm.Get(doc, idx)
+							cumsum += docPart[tid]
+							t = tid
+							if cumsum >= u {
+								break
+							}
+						}
+					} else { // stale dense part
+						t = table.Sample(this.rng.Float32())
+						this.draws[w] += 1
+					}
+					if t == s {
+						continue
+					}
+
+					// acceptance ratio p(t)q(s) / p(s)q(t)
+					wps := this.wordPart(w, s)
+					wpt := this.wordPart(w, t)
+					ps := (this.Alpha + float32(this.Dt.Get(doc, s))) * wps
+					pt := (this.Alpha + float32(this.Dt.Get(doc, t))) * wpt
+					qs := float32(this.Dt.Get(doc, s))*wps + table.Weight[s]
+					qt := float32(this.Dt.Get(doc, t))*wpt + table.Weight[t]
+					if this.rng.Float32()*ps*qt < pt*qs {
+						s = t
+					}
+				}
+				k = s
+
+				// increase corresponding sufficient statistics
+				this.Wt.Incr(w, k, uint32(1))
+				this.Dt.Incr(doc, k, uint32(1))
+				this.Dtm.Incr(doc, k, uint32(1))
+				this.Wts.Incr(k, uint32(0), uint32(1))
+				this.Dwt[dw] = k
+			}
+		}
+	}
+}
+
+func (this *AliasLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	// create sstables
+	this.Wt = sstable.NewUint32Matrix(dat.VocabSize, this.TopicNum)
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init()
+	this.initTables()
+
+	this.ResampleTopics(iter)
+}
+
+// infer topics on new documents
+func (this *AliasLDA) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.Wts == nil {
+		log.Fatal("Wt or Wts is not initialized, maybe model is not loaded")
+	}
+	// Wt, Wts should be initialized when model was loaded
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init()
+	this.initTables()
+
+	this.ResampleTopics(iter)
+}
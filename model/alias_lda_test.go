@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestAliasLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewAliasLDA(uint32(4), float32(0.1), float32(0.01)).(*AliasLDA)
+	m.Seed(1)
+	m.Train(dat, 10)
+
+	assertCounts(t, dat, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertDocWordTopics(t, dat, m.TopicNum, m.Wt, m.Dt, m.Dwt)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	m.Infer(dat, 5)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestAliasLDAPosterior(t *testing.T) {
+	// the stale alias tables are only a proposal, the metropolis
+	// hastings steps should still sample the exact posterior
+	dat, wt, wts := newTwoTokenCorpus()
+	m := NewAliasLDA(uint32(3), float32(0.5), float32(0.1)).(*AliasLDA)
+	m.Seed(1)
+	posterior := twoTokenPosterior(m.Alpha, m.Beta, wt, wts)
+	m.Wt, m.Wts = wt, wts
+	m.Infer(dat, 1)
+
+	assertTwoTokenPosterior(t, posterior, 200000, func() { m.ResampleTopics(1) },
+		func() map[sstable.DocWord]uint32 { return m.Dwt })
+}
@@ -0,0 +1,336 @@
+package model
+
+import (
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("atm", NewAuthorTopic)
+}
+
+// AuthorTopic is the author-topic model of Rosen-Zvi et al. (2004),
+// each author has a topic mixture and every token of a document is
+// generated by one of the document authors, so each token is assigned
+// an (author, topic) pair by the collapsed gibbs sampler. The corpus
+// should carry the authors of every document. Dt still counts the
+// topics of each document so that Theta gives the document-topic
+// mixtures as usual.
+type AuthorTopic struct {
+	*ldaBase
+	At  *sstable.Uint32Matrix      // author-topic count table
+	Ats *sstable.Uint32Matrix      // author-topic-sum count table
+	Dwa map[sstable.DocWord]uint32 // doc-word-author map
+}
+
+// NewAuthorTopic creates an author-topic model instance with collapsed
+// gibbs sampler
+func NewAuthorTopic(topicNum uint32, alpha float32, beta float32) Model {
+	return &AuthorTopic{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+	}
+}
+
+// get the authors of document doc, it fails if the corpus has no
+// author for the document
+func (this *AuthorTopic) authors(doc uint32) []uint32 {
+	authors := this.Data.Authors[doc]
+	if len(authors) == 0 {
+		log.Fatalf("document %d has no author, maybe author file is not loaded", doc)
+	}
+	return authors
+}
+
+// randomly assign author and topic to word
+func (this *AuthorTopic) Init() {
+	dw := sstable.DocWord{}
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		authors := this.authors(doc)
+		for i, w := range corpus.ExpandWords(wcs) {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			a := authors[this.rng.Intn(len(authors))]
+
+			this.Wt.Incr(w, k, uint32(1))
+			this.Dt.Incr(doc, k, uint32(1))
+			this.Wts.Incr(k, uint32(0), uint32(1))
+			this.At.Incr(a, k, uint32(1))
+			this.Ats.Incr(a, uint32(0), uint32(1))
+
+			dw.DocId = doc
+			dw.WordIdx = uint32(i)
+			this.Dwt[dw] = k
+			this.Dwa[dw] = a
+		}
+	}
+}
+
+func (this *AuthorTopic) ResampleTopics(iter int) {
+	dw := sstable.DocWord{}
+	var cumsum []float32
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			authors := this.authors(doc)
+			if size := uint32(len(authors)) * this.TopicNum; uint32(len(cumsum)) < size {
+				cumsum = make([]float32, size)
+			}
+			for i, w := range corpus.ExpandWords(wcs) {
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+				a := this.Dwa[dw]
+
+				// decrease corresponding sufficient statistics
+				this.Wt.Decr(w, k, uint32(1))
+				this.Dt.Decr(doc, k, uint32(1))
+				this.Wts.Decr(k, uint32(0), uint32(1))
+				this.At.Decr(a, k, uint32(1))
+				this.Ats.Decr(a, uint32(0), uint32(1))
+
+				// resample the author and topic
+				total := float32(0.0)
+				for aidx, author := range authors {
+					authorSum := float32(this.Ats.Get(author, uint32(0))) +
+						float32(this.TopicNum)*this.Alpha
+					for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+						authorPart := (this.Alpha + float32(this.At.Get(author, kidx))) /
+							authorSum
+						wordPart := (this.Beta + float32(this.Wt.Get(w, kidx))) /
+							(float32(this.Wts.Get(kidx, uint32(0))) +
+								this.Beta*float32(this.Data.VocabSize))
+						total += authorPart * wordPart
+						cumsum[uint32(aidx)*this.TopicNum+kidx] = total
+					}
+				}
+				u := this.rng.Float32() * total
+				size := uint32(len(authors)) * this.TopicNum
+				idx := uint32(0)
+				for ; idx < size-1; idx += 1 {
+					if u < cumsum[idx] {
+						break
+					}
+				}
+				a = authors[idx/this.TopicNum]
+				k = idx % this.TopicNum
+
+				// increase corresponding sufficient statistics
+				this.Wt.Incr(w, k, uint32(1))
+				this.Dt.Incr(doc, k, uint32(1))
+				this.Wts.Incr(k, uint32(0), uint32(1))
+				this.At.Incr(a, k, uint32(1))
+				this.Ats.Incr(a, uint32(0), uint32(1))
+				this.Dwt[dw] = k
+				this.Dwa[dw] = a
+			}
+		}
+	}
+}
+
+func (this *AuthorTopic) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if dat.AuthorNum == 0 {
+		log.Fatal("corpus has no author, maybe author file is not loaded")
+	}
+	// create sstables
+	this.Wt = sstable.NewUint32Matrix(dat.VocabSize, this.TopicNum)
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	this.At = sstable.NewUint32Matrix(dat.AuthorNum, this.TopicNum)
+	this.Ats = sstable.NewUint32Matrix(dat.AuthorNum, uint32(1))
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Dwa = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init()
+
+	this.ResampleTopics(iter)
+}
+
+// infer topics on new documents, the word-topic counts are held fixed
+// and the author-topic counts of the new documents are added to the
+// ones of the loaded model, so each token is sampled conditioned on
+// the current assignments of the other tokens of its authors. Authors
+// not seen in training start with uniform topic mixtures
+func (this *AuthorTopic) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.At == nil {
+		log.Fatal("Wt or At is not initialized, maybe model is not loaded")
+	}
+	vocabSize, _ := this.Wt.Shape()
+	authorNum, _ := this.At.Shape()
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Dwa = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// author-topic counts of the new documents
+	at := sstable.NewUint32Matrix(dat.AuthorNum, this.TopicNum)
+	ats := sstable.NewUint32Matrix(dat.AuthorNum, uint32(1))
+
+	// p(a, k | w) ~ (n_ak+alpha)/(n_a+K*alpha) * phi_wk with phi of the
+	// loaded model and n_ak of both the loaded model and new documents
+	prob := func(a, w, k uint32) float32 {
+		authorCount := float32(at.Get(a, k))
+		authorSum := float32(ats.Get(a, uint32(0)))
+		if a < authorNum {
+			authorCount += float32(this.At.Get(a, k))
+			authorSum += float32(this.Ats.Get(a, uint32(0)))
+		}
+		authorPart := (this.Alpha + authorCount) /
+			(authorSum + float32(this.TopicNum)*this.Alpha)
+		wordCount := uint32(0)
+		if w < vocabSize {
+			wordCount = this.Wt.Get(w, k)
+		}
+		wordPart := (this.Beta + float32(wordCount)) /
+			(float32(this.Wts.Get(k, uint32(0))) +
+				this.Beta*float32(vocabSize))
+		return authorPart * wordPart
+	}
+
+	// randomly assign author and topic to word
+	dw := sstable.DocWord{}
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		authors := this.authors(doc)
+		for i, _ := range corpus.ExpandWords(wcs) {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			a := authors[this.rng.Intn(len(authors))]
+
+			this.Dt.Incr(doc, k, uint32(1))
+			at.Incr(a, k, uint32(1))
+			ats.Incr(a, uint32(0), uint32(1))
+
+			dw.DocId = doc
+			dw.WordIdx = uint32(i)
+			this.Dwt[dw] = k
+			this.Dwa[dw] = a
+		}
+	}
+
+	var cumsum []float32
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			authors := this.authors(doc)
+			size := uint32(len(authors)) * this.TopicNum
+			if uint32(len(cumsum)) < size {
+				cumsum = make([]float32, size)
+			}
+			for i, w := range corpus.ExpandWords(wcs) {
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+				a := this.Dwa[dw]
+
+				// decrease corresponding sufficient statistics
+				this.Dt.Decr(doc, k, uint32(1))
+				at.Decr(a, k, uint32(1))
+				ats.Decr(a, uint32(0), uint32(1))
+
+				// resample the author and topic
+				total := float32(0.0)
+				for aidx, author := range authors {
+					for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+						total += prob(author, w, kidx)
+						cumsum[uint32(aidx)*this.TopicNum+kidx] = total
+					}
+				}
+				u := this.rng.Float32() * total
+				idx := uint32(0)
+				for ; idx < size-1; idx += 1 {
+					if u < cumsum[idx] {
+						break
+					}
+				}
+				a = authors[idx/this.TopicNum]
+				k = idx % this.TopicNum
+
+				// increase corresponding sufficient statistics
+				this.Dt.Incr(doc, k, uint32(1))
+				at.Incr(a, k, uint32(1))
+				ats.Incr(a, uint32(0), uint32(1))
+				this.Dwt[dw] = k
+				this.Dwa[dw] = a
+			}
+		}
+	}
+}
+
+// compute the posterior point estimation of author-topic mixture
+// alpha (Dirichlet prior) + data -> theta of authors
+func (this *AuthorTopic) AuthorTheta() *sstable.Float32Matrix {
+	authorNum, _ := this.At.Shape()
+	theta := sstable.NewFloat32Matrix(authorNum, this.TopicNum)
+
+	for a := uint32(0); a < authorNum; a += 1 {
+		sum := this.Ats.Get(a, uint32(0))
+
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			result := (float32(this.At.Get(a, k)) + this.Alpha) /
+				(float32(sum) + float32(this.TopicNum)*this.Alpha)
+			theta.Set(a, k, result)
+		}
+	}
+
+	return theta
+}
+
+// serialize author-topic distribution
+func (this *AuthorTopic) SaveAuthorTopic(fn string) error {
+	theta := this.AuthorTheta()
+	if err := sstable.Float32Serialize(theta, fn); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize word-topic matrix, the author-topic counts are saved in
+// another file with suffix .author and the hyperparameters in .hyper
+func (this *AuthorTopic) SaveWordTopic(fn string) error {
+	if err := sstable.Uint32Serialize(this.Wt, fn); err != nil {
+		return err
+	}
+	if err := sstable.Uint32Serialize(this.At, fn+".author"); err != nil {
+		return err
+	}
+	if err := this.saveHyperParams(fn + ".hyper"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deserialize word-topic matrix and author-topic counts
+func (this *AuthorTopic) LoadWordTopic(fn string) error {
+	if err := this.ldaBase.LoadWordTopic(fn); err != nil {
+		return err
+	}
+	v, err := sstable.Uint32Deserialize(fn + ".author")
+	if err != nil {
+		return err
+	}
+	this.At = v
+	// init AuthorTopicSum table
+	authorNum, topicNum := this.At.Shape()
+	this.Ats = sstable.NewUint32Matrix(authorNum, uint32(1))
+	for r := uint32(0); r < authorNum; r += 1 {
+		for t := uint32(0); t < topicNum; t += 1 {
+			this.Ats.Incr(r, uint32(0), this.At.Get(r, t))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestAuthorTopic(t *testing.T) {
+	dat := newTestCorpus()
+	dat.Authors = make(map[uint32][]uint32)
+	for doc := uint32(0); doc < dat.DocNum; doc += 1 {
+		dat.Authors[doc] = []uint32{doc % 2, 2}
+	}
+	dat.AuthorNum = 3
+
+	m := NewAuthorTopic(uint32(4), float32(0.1), float32(0.01)).(*AuthorTopic)
+	m.Seed(1)
+	m.Train(dat, 10)
+
+	assertCounts(t, m.Data, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	docLen, _ := tokenCounts(dat)
+	total := uint32(0)
+	for _, n := range docLen {
+		total += n
+	}
+	assert.Equal(t, total, sstable.Uint32VectorSum(m.Ats.GetCol(0)))
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	// the trained author counts are left untouched by inference
+	var at []uint32
+	for a := uint32(0); a < dat.AuthorNum; a += 1 {
+		at = append(at, m.At.GetRow(a)...)
+	}
+	m.Infer(dat, 5)
+	for a := uint32(0); a < dat.AuthorNum; a += 1 {
+		assert.Equal(t, at[a*m.TopicNum:(a+1)*m.TopicNum], m.At.GetRow(a))
+	}
+	for doc := uint32(0); doc < dat.DocNum; doc += 1 {
+		assert.Equal(t, docLen[doc], sstable.Uint32VectorSum(m.Dt.GetRow(doc)))
+	}
+	for dw, a := range m.Dwa {
+		assert.Contains(t, dat.Authors[dw.DocId], a)
+	}
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+// running sum of matrix samples
+type sampleSum struct {
+	nrow uint32
+	ncol uint32
+	sum  []float64
+	n    int
+}
+
+// add matrix m to the sum
+func (this *sampleSum) add(m *sstable.Float32Matrix) {
+	if this.sum == nil {
+		this.nrow, this.ncol = m.Shape()
+		this.sum = make([]float64, this.nrow*this.ncol)
+	}
+	for r := uint32(0); r < this.nrow; r += 1 {
+		for c := uint32(0); c < this.ncol; c += 1 {
+			this.sum[r*this.ncol+c] += float64(m.Get(r, c))
+		}
+	}
+	this.n += 1
+}
+
+// compute the mean of the samples
+func (this *sampleSum) mean() *sstable.Float32Matrix {
+	m := sstable.NewFloat32Matrix(this.nrow, this.ncol)
+	for r := uint32(0); r < this.nrow; r += 1 {
+		for c := uint32(0); c < this.ncol; c += 1 {
+			m.Set(r, c, float32(this.sum[r*this.ncol+c]/float64(this.n)))
+		}
+	}
+	return m
+}
+
+// average phi and theta over samples taken every thin training
+// iterations after burnin iterations, averaging is disabled if thin is
+// not positive
+func (this *LDA) SetAveraging(burnin, thin int) error {
+	if burnin < 0 {
+		return fmt.Errorf("burnin should be non-negative: %d", burnin)
+	}
+	this.BurnIn = burnin
+	this.Thin = thin
+	return nil
+}
+
+// warn if averaging is enabled but training has finished before the
+// first sample, phi and theta are the ones of the final state then
+func (this *ldaBase) checkSamples() {
+	if this.Thin > 0 && this.phiSum == nil {
+		log.Warningf("no sample is taken in %d iterations after burn-in %d, phi and theta are not averaged",
+			this.Iteration, this.BurnIn)
+	}
+}
+
+// whether a sample should be taken after current iteration
+func (this *ldaBase) sampleDue() bool {
+	return this.Thin > 0 && this.Iteration > this.BurnIn &&
+		(this.Iteration-this.BurnIn)%this.Thin == 0
+}
+
+// add phi and theta of the current state to the sample sums
+func (this *ldaBase) takeSample(phi, theta *sstable.Float32Matrix) {
+	if this.phiSum == nil {
+		this.phiSum = &sampleSum{}
+		this.thetaSum = &sampleSum{}
+	}
+	this.phiSum.add(phi)
+	this.thetaSum.add(theta)
+}
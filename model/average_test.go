@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestSampleSum(t *testing.T) {
+	a := sstable.NewFloat32Matrix(uint32(2), uint32(3))
+	b := sstable.NewFloat32Matrix(uint32(2), uint32(3))
+	for r := uint32(0); r < 2; r += 1 {
+		for c := uint32(0); c < 3; c += 1 {
+			a.Set(r, c, float32(r*3+c))
+			b.Set(r, c, float32(10*(r*3+c)+1))
+		}
+	}
+
+	s := &sampleSum{}
+	s.add(a)
+	s.add(b)
+	assert.Equal(t, 2, s.n)
+	mean := s.mean()
+	row, col := mean.Shape()
+	assert.Equal(t, uint32(2), row)
+	assert.Equal(t, uint32(3), col)
+	assert.InDelta(t, 0.5, mean.Get(0, 0), 1e-6)
+	assert.InDelta(t, 6.0, mean.Get(0, 1), 1e-6)
+	assert.InDelta(t, 28.0, mean.Get(1, 2), 1e-6)
+}
+
+func TestAveraging(t *testing.T) {
+	m := NewLDA(uint32(4), float32(0.1), float32(0.01)).(*LDA)
+	m.Seed(1)
+	assert.NotNil(t, m.SetAveraging(-1, 2))
+	assert.Nil(t, m.SetAveraging(3, 2))
+	m.Train(newTestCorpus(), 8)
+
+	// samples after iterations 5 and 7
+	assert.Equal(t, 2, m.phiSum.n)
+	assert.Equal(t, 2, m.thetaSum.n)
+	assertDistributions(t, m.Phi(), m.Theta())
+	assert.NotEqual(t, m.statePhi(), m.Phi())
+
+	for _, ctor := range []ModelCtor{NewAliasLDA, NewFTreeLDA, NewWarpLDA,
+		NewLightLDA, NewPYPLDA, NewHDP, NewAuthorTopic, NewADLDA} {
+		_, ok := ctor(uint32(4), 0.1, 0.01).(SampleAverager)
+		assert.False(t, ok)
+	}
+}
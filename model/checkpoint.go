@@ -0,0 +1,161 @@
+package model
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+// the full state of the collapsed gibbs sampler after Iteration
+// training iterations
+type ldaCheckpoint struct {
+	Iteration int
+	TopicNum  uint32
+	DocNum    uint32
+	VocabSize uint32
+	Alphas    []float32
+	Beta      float32
+	RNGState  uint64
+
+	Wt  *sstable.Uint32Matrix
+	Dt  *sstable.Uint32Matrix
+	Wts *sstable.Uint32Matrix
+	Dwt map[sstable.DocWord]uint32
+
+	Samples  int       // number of phi and theta samples after burn-in
+	PhiRows  uint32    // number of rows of sampled phi
+	PhiSum   []float64 // sum of sampled phi
+	ThetaSum []float64 // sum of sampled theta
+}
+
+// write checkpoint to file fn every interval training iterations,
+// checkpoints are disabled if interval is not positive
+func (this *LDA) SetCheckpoint(fn string, interval int) {
+	this.CheckpointFile = fn
+	this.CheckpointInterval = interval
+}
+
+// whether a checkpoint should be written after current iteration
+func (this *ldaBase) checkpointDue() bool {
+	return this.CheckpointInterval > 0 && this.CheckpointFile != "" &&
+		this.Iteration%this.CheckpointInterval == 0
+}
+
+// write checkpoint with word-topic counts wt, training goes on if the
+// checkpoint cannot be written
+func (this *ldaBase) checkpoint(wt *sstable.Uint32Matrix) {
+	if err := this.saveCheckpoint(this.CheckpointFile, wt); err != nil {
+		log.Errorf("fail to write checkpoint of iter %d: %v", this.Iteration, err)
+		return
+	}
+	log.Infof("checkpoint of iter %d written to %s", this.Iteration, this.CheckpointFile)
+}
+
+// serialize sampler state with word-topic counts wt, the state is
+// written to a temporary file first and renamed to fn, so fn always
+// holds a complete checkpoint even if the process dies while writing
+func (this *ldaBase) saveCheckpoint(fn string, wt *sstable.Uint32Matrix) error {
+	state := &ldaCheckpoint{
+		Iteration: this.Iteration,
+		TopicNum:  this.TopicNum,
+		DocNum:    this.Data.DocNum,
+		VocabSize: this.Data.VocabSize,
+		Alphas:    this.Alphas,
+		Beta:      this.Beta,
+		RNGState:  this.source.state,
+		Wt:        wt,
+		Dt:        this.Dt,
+		Wts:       this.Wts,
+		Dwt:       this.Dwt,
+	}
+	if this.phiSum != nil {
+		state.Samples = this.phiSum.n
+		state.PhiRows = this.phiSum.nrow
+		state.PhiSum = this.phiSum.sum
+		state.ThetaSum = this.thetaSum.sum
+	}
+
+	tmp := fn + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(out).Encode(state); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// restore sampler state from checkpoint fn, the corpus should be the
+// one the checkpoint was trained on
+func (this *ldaBase) loadCheckpoint(dat *corpus.Corpus, fn string) error {
+	if dat == nil {
+		return fmt.Errorf("corpus is nil")
+	}
+	in, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	state := &ldaCheckpoint{}
+	if err := gob.NewDecoder(in).Decode(state); err != nil {
+		return err
+	}
+	if state.TopicNum != this.TopicNum {
+		return fmt.Errorf("checkpoint has %d topics, %d expected", state.TopicNum, this.TopicNum)
+	}
+	if state.DocNum != dat.DocNum || state.VocabSize != dat.VocabSize {
+		return fmt.Errorf("checkpoint of %d docs and %d words, corpus has %d docs and %d words",
+			state.DocNum, state.VocabSize, dat.DocNum, dat.VocabSize)
+	}
+	if state.Samples > 0 && (len(state.PhiSum) != int(state.PhiRows*state.TopicNum) ||
+		len(state.ThetaSum) != int(state.DocNum*state.TopicNum)) {
+		return fmt.Errorf("checkpoint has sample sums of bad size")
+	}
+
+	this.Data = dat
+	this.Iteration = state.Iteration
+	this.Alphas = state.Alphas
+	this.Beta = state.Beta
+	this.source.state = state.RNGState
+	this.Wt = state.Wt
+	this.Dt = state.Dt
+	this.Wts = state.Wts
+	this.Dwt = state.Dwt
+	this.phiSum, this.thetaSum = nil, nil
+	if state.Samples > 0 {
+		if this.Thin > 0 {
+			this.phiSum = &sampleSum{nrow: state.PhiRows, ncol: state.TopicNum,
+				sum: state.PhiSum, n: state.Samples}
+			this.thetaSum = &sampleSum{nrow: state.DocNum, ncol: state.TopicNum,
+				sum: state.ThetaSum, n: state.Samples}
+		} else {
+			log.Warningf("averaging is disabled, %d samples of checkpoint are dropped", state.Samples)
+		}
+	}
+	log.Infof("resume from checkpoint of iter %d", this.Iteration)
+	return nil
+}
+
+// continue training from checkpoint fn until iter iterations are
+// finished in total
+func (this *LDA) Resume(dat *corpus.Corpus, fn string, iter int) error {
+	if err := this.loadCheckpoint(dat, fn); err != nil {
+		return err
+	}
+	this.training = true
+	this.ResampleTopics(iter - this.Iteration)
+	this.training = false
+	this.checkSamples()
+	return nil
+}
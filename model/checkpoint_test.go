@@ -0,0 +1,64 @@
+package model
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "model.ckpt")
+	dat := newTestCorpus()
+
+	for _, ctor := range []ModelCtor{NewLDA, NewSparseLDA} {
+		full := ctor(uint32(4), float32(0.1), float32(0.01))
+		full.(Seeder).Seed(1)
+		full.Train(dat, 6)
+
+		// train 4 iterations with checkpoints every 2, and resume
+		// from the checkpoint of iter 4 to finish 6 iterations
+		part := ctor(uint32(4), float32(0.1), float32(0.01))
+		part.(Seeder).Seed(1)
+		part.(Checkpointer).SetCheckpoint(fn, 2)
+		part.Train(dat, 4)
+
+		resumed := ctor(uint32(4), float32(0.1), float32(0.01))
+		assert.Nil(t, resumed.(Checkpointer).Resume(dat, fn, 6))
+		assert.Equal(t, full.Phi(), resumed.Phi())
+		assert.Equal(t, full.Theta(), resumed.Theta())
+	}
+}
+
+func TestCheckpointResumeAveraging(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "model.ckpt")
+	dat := newTestCorpus()
+
+	for _, ctor := range []ModelCtor{NewLDA, NewSparseLDA} {
+		full := ctor(uint32(4), float32(0.1), float32(0.01))
+		full.(Seeder).Seed(1)
+		assert.Nil(t, full.(SampleAverager).SetAveraging(2, 1))
+		full.Train(dat, 8)
+
+		// the checkpoint of iter 4 holds the samples of iters 3 and 4
+		part := ctor(uint32(4), float32(0.1), float32(0.01))
+		part.(Seeder).Seed(1)
+		assert.Nil(t, part.(SampleAverager).SetAveraging(2, 1))
+		part.(Checkpointer).SetCheckpoint(fn, 2)
+		part.Train(dat, 5)
+
+		resumed := ctor(uint32(4), float32(0.1), float32(0.01))
+		assert.Nil(t, resumed.(SampleAverager).SetAveraging(2, 1))
+		assert.Nil(t, resumed.(Checkpointer).Resume(dat, fn, 8))
+		assert.Equal(t, full.Phi(), resumed.Phi())
+		assert.Equal(t, full.Theta(), resumed.Theta())
+	}
+}
+
+func TestCheckpointSupport(t *testing.T) {
+	for _, ctor := range []ModelCtor{NewAliasLDA, NewFTreeLDA, NewWarpLDA,
+		NewLightLDA, NewPYPLDA, NewHDP, NewAuthorTopic, NewADLDA} {
+		_, ok := ctor(uint32(4), 0.1, 0.01).(Checkpointer)
+		assert.False(t, ok)
+	}
+}
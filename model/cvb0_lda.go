@@ -0,0 +1,289 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("cvb0", NewCVB0)
+}
+
+// CVB0 is the zero order collapsed variational bayes algorithm of
+// Asuncion et al. (2009). Instead of sampling a topic for every token
+// it keeps the topic responsibilities of every word of a document and
+// replaces the gibbs counts by their expectations, the update of a
+// responsibility is the collapsed gibbs conditional evaluated with the
+// expected counts excluding the word itself. All tokens of the same
+// word in a document share one responsibility vector. Documents are
+// visited in ascending order of id, so training the same corpus gives
+// the same model if the random initialization is seeded by Seed.
+type CVB0 struct {
+	Alpha    float32 // document topic mixture hyperparameter
+	Beta     float32 // topic word mixture hyperparameter
+	TopicNum uint32
+
+	Data *corpus.Corpus // for convenience
+
+	Nwk *sstable.Float32Matrix // expected word-topic count table
+	Ndk *sstable.Float32Matrix // expected doc-topic count table
+	Nk  []float64              // expected topic count
+
+	resp map[uint32][]float32 // responsibilities of each word of documents
+	rng  *rand.Rand
+}
+
+// NewCVB0 creates a LDA instance with collapsed variational bayes
+// zero order inference
+func NewCVB0(topicNum uint32, alpha float32, beta float32) Model {
+	return &CVB0{
+		Alpha:    alpha,
+		Beta:     beta,
+		TopicNum: topicNum,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// seed the random source of responsibility initialization
+func (this *CVB0) Seed(seed int64) {
+	this.rng = rand.New(rand.NewSource(seed))
+}
+
+// randomly init responsibilities and accumulate the expected counts,
+// Nwk and Nk are only touched if updateWords is true
+func (this *CVB0) init(updateWords bool) {
+	this.Ndk = sstable.NewFloat32Matrix(this.Data.DocNum, this.TopicNum)
+	this.resp = make(map[uint32][]float32)
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		resp := make([]float32, uint32(len(wcs))*this.TopicNum)
+		for i, wc := range wcs {
+			gamma := resp[uint32(i)*this.TopicNum : uint32(i+1)*this.TopicNum]
+			sum := float32(0.0)
+			for k, _ := range gamma {
+				gamma[k] = this.rng.Float32() + 1e-3
+				sum += gamma[k]
+			}
+			for k, _ := range gamma {
+				gamma[k] /= sum
+			}
+			this.add(doc, wc, gamma, 1.0, updateWords)
+		}
+		this.resp[doc] = resp
+	}
+}
+
+// add sign times the expected counts of word count wc with topic
+// responsibilities gamma to the count tables
+func (this *CVB0) add(doc uint32, wc *corpus.WordCount, gamma []float32,
+	sign float32, updateWords bool) {
+	count := sign * float32(wc.Count)
+	for k, g := range gamma {
+		this.Ndk.Set(doc, uint32(k), this.Ndk.Get(doc, uint32(k))+count*g)
+		if updateWords {
+			this.Nwk.Set(wc.WordId, uint32(k), this.Nwk.Get(wc.WordId, uint32(k))+count*g)
+			this.Nk[k] += float64(count * g)
+		}
+	}
+}
+
+// sweep all documents once, the word-topic counts are held fixed if
+// updateWords is false, the mean absolute change of responsibilities
+// is returned
+func (this *CVB0) sweep(updateWords bool) float64 {
+	vocabSize, _ := this.Nwk.Shape()
+	betaSum := float64(this.Beta) * float64(vocabSize)
+	buffer := make([]float32, this.TopicNum)
+	change := 0.0
+	entries := 0
+
+	for _, doc := range this.Data.DocIds() {
+		resp := this.resp[doc]
+		for i, wc := range this.Data.Docs[doc] {
+			gamma := resp[uint32(i)*this.TopicNum : uint32(i+1)*this.TopicNum]
+			// exclude one token of the word from the expected counts
+			for k, g := range gamma {
+				ndk := float64(this.Ndk.Get(doc, uint32(k)) - g)
+				nwk, nk := 0.0, this.Nk[k]
+				if wc.WordId < vocabSize {
+					nwk = float64(this.Nwk.Get(wc.WordId, uint32(k)))
+				}
+				if updateWords {
+					nwk -= float64(g)
+					nk -= float64(g)
+				}
+				val := (math.Max(ndk, 0) + float64(this.Alpha)) *
+					(math.Max(nwk, 0) + float64(this.Beta)) /
+					(math.Max(nk, 0) + betaSum)
+				buffer[k] = float32(val)
+			}
+			sum := sstable.Float32VectorSum(buffer)
+			for k, _ := range buffer {
+				buffer[k] /= sum
+				change += math.Abs(float64(buffer[k] - gamma[k]))
+			}
+
+			// replace the old responsibilities of all tokens of the word
+			this.add(doc, wc, gamma, -1.0, updateWords)
+			copy(gamma, buffer)
+			this.add(doc, wc, gamma, 1.0, updateWords)
+			entries += 1
+		}
+	}
+
+	if entries == 0 {
+		return 0.0
+	}
+	return change / float64(entries*int(this.TopicNum))
+}
+
+func (this *CVB0) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	this.Data = dat
+	this.Nwk = sstable.NewFloat32Matrix(dat.VocabSize, this.TopicNum)
+	this.Nk = make([]float64, this.TopicNum)
+	this.init(true)
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		change := this.sweep(true)
+		if log.V(5) {
+			log.Infof("iter %5d, mean responsibility change %g", iterIdx, change)
+		}
+	}
+}
+
+// infer topics on new documents, the expected word-topic counts of
+// the loaded model are held fixed
+func (this *CVB0) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Nwk == nil {
+		log.Fatal("Nwk is not initialized, maybe model is not loaded")
+	}
+	this.Data = dat
+	this.init(false)
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		this.sweep(false)
+	}
+}
+
+// compute the posterior point estimation of word-topic mixture from
+// the expected counts
+func (this *CVB0) Phi() *sstable.Float32Matrix {
+	vocabSize, _ := this.Nwk.Shape()
+	phi := sstable.NewFloat32Matrix(vocabSize, this.TopicNum)
+
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		for v := uint32(0); v < vocabSize; v += 1 {
+			result := (float64(this.Nwk.Get(v, k)) + float64(this.Beta)) /
+				(this.Nk[k] + float64(vocabSize)*float64(this.Beta))
+			phi.Set(v, k, float32(result))
+		}
+	}
+
+	return phi
+}
+
+// compute the posterior point estimation of document-topic mixture
+// from the expected counts
+func (this *CVB0) Theta() *sstable.Float32Matrix {
+	theta := sstable.NewFloat32Matrix(this.Data.DocNum, this.TopicNum)
+
+	for d := uint32(0); d < this.Data.DocNum; d += 1 {
+		sum := float32(0.0)
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			sum += this.Ndk.Get(d, k)
+		}
+
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			result := (this.Ndk.Get(d, k) + this.Alpha) /
+				(sum + float32(this.TopicNum)*this.Alpha)
+			theta.Set(d, k, result)
+		}
+	}
+
+	return theta
+}
+
+// compute the likelihood of corpus with the point estimations
+func (this *CVB0) Likelihood() float64 {
+	phi := this.Phi()
+	theta := this.Theta()
+
+	sum := float64(0.0)
+	for doc, wcs := range this.Data.Docs {
+		for _, wc := range wcs {
+			topicSum := float32(0.0)
+			for k := uint32(0); k < this.TopicNum; k += 1 {
+				topicSum += phi.Get(wc.WordId, k) * theta.Get(doc, k)
+			}
+			sum += float64(wc.Count) * math.Log(float64(topicSum))
+		}
+	}
+
+	return sum
+}
+
+// serialize word-topic distribution
+func (this *CVB0) SavePhi(fn string) error {
+	phi := this.Phi()
+	if err := sstable.Float32Serialize(phi, fn); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize document-topic distribution, rows are written with the
+// document keys of the corpus
+func (this *CVB0) SaveTheta(fn string) error {
+	theta := this.Theta()
+	if err := sstable.Float32SerializeRows(theta, this.Data.DocKeys(), fn); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize expected word-topic count table, a stale .hyper of the
+// table is removed
+func (this *CVB0) SaveWordTopic(fn string) error {
+	if err := sstable.Float32Serialize(this.Nwk, fn); err != nil {
+		return err
+	}
+	return removeHyperParams(fn)
+}
+
+// deserialize expected word-topic count table
+func (this *CVB0) LoadWordTopic(fn string) error {
+	v, err := sstable.Float32Deserialize(fn)
+	if err != nil {
+		return err
+	}
+	vocab, topicNum := v.Shape()
+	if topicNum != this.TopicNum {
+		return fmt.Errorf("model has %d topics, %d expected", topicNum, this.TopicNum)
+	}
+	this.Nwk = v
+	this.Nk = make([]float64, this.TopicNum)
+	for w := uint32(0); w < vocab; w += 1 {
+		for k := uint32(0); k < topicNum; k += 1 {
+			this.Nk[k] += float64(this.Nwk.Get(w, k))
+		}
+	}
+	return nil
+}
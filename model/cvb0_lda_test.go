@@ -0,0 +1,88 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestCVB0(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewCVB0(uint32(4), float32(0.1), float32(0.01)).(*CVB0)
+	m.Train(dat, 10)
+
+	// the responsibilities of every token sum to one, so the expected
+	// counts have the same marginals as the gibbs counts
+	docLen, wordFreq := tokenCounts(dat)
+	for doc := uint32(0); doc < dat.DocNum; doc += 1 {
+		sum := float32(0.0)
+		for k := uint32(0); k < m.TopicNum; k += 1 {
+			sum += m.Ndk.Get(doc, k)
+		}
+		assert.InDelta(t, float32(docLen[doc]), sum, 1e-3)
+	}
+	for w := uint32(0); w < dat.VocabSize; w += 1 {
+		sum := float32(0.0)
+		for k := uint32(0); k < m.TopicNum; k += 1 {
+			sum += m.Nwk.Get(w, k)
+		}
+		assert.InDelta(t, float32(wordFreq[w]), sum, 1e-3)
+	}
+	total := 0.0
+	for k := uint32(0); k < m.TopicNum; k += 1 {
+		total += m.Nk[k]
+	}
+	tokenNum := uint32(0)
+	for _, n := range docLen {
+		tokenNum += n
+	}
+	assert.InDelta(t, float64(tokenNum), total, 1e-2)
+	assertResponsibilities(t, m)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	// the expected word-topic counts are held fixed in inference
+	nwk := sstable.NewFloat32Matrix(dat.VocabSize, m.TopicNum)
+	for w := uint32(0); w < dat.VocabSize; w += 1 {
+		for k := uint32(0); k < m.TopicNum; k += 1 {
+			nwk.Set(w, k, m.Nwk.Get(w, k))
+		}
+	}
+	nk := append([]float64(nil), m.Nk...)
+	m.Infer(newHeldOutCorpus(), 5)
+	assert.Equal(t, nwk, m.Nwk)
+	assert.Equal(t, nk, m.Nk)
+	assertResponsibilities(t, m)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+// check every responsibility vector is a distribution and the expected
+// doc-topic counts are the summation of the responsibilities
+func assertResponsibilities(t *testing.T, m *CVB0) {
+	for doc, wcs := range m.Data.Docs {
+		resp := m.resp[doc]
+		for k := uint32(0); k < m.TopicNum; k += 1 {
+			ndk := float32(0.0)
+			for i, wc := range wcs {
+				ndk += float32(wc.Count) * resp[uint32(i)*m.TopicNum+k]
+			}
+			assert.InDelta(t, ndk, m.Ndk.Get(doc, k), 1e-3)
+		}
+		for i, _ := range wcs {
+			gamma := resp[uint32(i)*m.TopicNum : uint32(i+1)*m.TopicNum]
+			assert.InDelta(t, 1.0, sstable.Float32VectorSum(gamma), 1e-4)
+		}
+	}
+}
+
+func TestCVB0Seed(t *testing.T) {
+	dat := newTestCorpus()
+	models := make([]*CVB0, 2)
+	for i, _ := range models {
+		models[i] = NewCVB0(uint32(4), float32(0.1), float32(0.01)).(*CVB0)
+		models[i].Seed(7)
+		models[i].Train(dat, 5)
+	}
+	assert.Equal(t, models[0].Nwk, models[1].Nwk)
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("ftreelda", NewFTreeLDA)
+}
+
+// FTreeLDA samples the corpus word by word. The collapsed conditional
+// of word w is split into alpha*(n_wk+beta)/(n_k+beta*V), which is kept
+// in a F+ tree over topics while the tokens of w are visited, and the
+// sparse n_dk*(n_wk+beta)/(n_k+beta*V) computed in O(k_d). Since only
+// the counts of the current word change during its pass, the tree
+// stays exact with O(log K) updates and the sampler is an exact
+// collapsed gibbs sampler.
+type FTreeLDA struct {
+	*ldaBase
+	Dtm *sstable.SortedMap // nonzero doc-topic counts
+
+	tree       *sstable.FTree      // topic-word part of current word
+	wordTokens [][]sstable.DocWord // tokens of each word
+}
+
+// NewFTreeLDA creates a F+ tree lda instance with O(log K) sampling
+// and updating time per token
+func NewFTreeLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &FTreeLDA{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+	}
+}
+
+// the current topic-word part (n_wk+beta)/(n_k+beta*V) of the conditional
+func (this *FTreeLDA) wordPart(w, k uint32) float32 {
+	return (this.Beta + float32(this.Wt.Get(w, k))) /
+		(float32(this.Wts.Get(k, uint32(0))) +
+			this.Beta*float32(this.Data.VocabSize))
+}
+
+// init doc-topic map and word token index after the count tables
+// are randomly initialized
+func (this *FTreeLDA) initTables() {
+	this.Dtm = sstable.NewSortedMap(this.TopicNum)
+	this.wordTokens = make([][]sstable.DocWord, this.Data.VocabSize)
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			if cnt := this.Dt.Get(doc, k); cnt > 0 {
+				this.Dtm.Incr(doc, k, cnt)
+			}
+		}
+		for i, w := range corpus.ExpandWords(wcs) {
+			this.wordTokens[w] = append(this.wordTokens[w], sstable.DocWord{
+				DocId:   doc,
+				WordIdx: uint32(i),
+			})
+		}
+	}
+	this.tree = sstable.NewFTree(this.TopicNum)
+}
+
+func (this *FTreeLDA) ResampleTopics(iter int) {
+	weights := make([]float32, this.TopicNum)
+	// cache of the sparse document part of current token
+	docPart := make([]float32, this.TopicNum)
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		for w, tokens := range this.wordTokens {
+			if len(tokens) == 0 {
+				continue
+			}
+			word := uint32(w)
+
+			// build the tree of current word
+			for k := uint32(0); k < this.TopicNum; k += 1 {
+				weights[k] = this.wordPart(word, k)
+			}
+			this.tree.Build(weights)
+
+			for _, dw := range tokens {
+				doc := dw.DocId
+				k := this.Dwt[dw]
+
+				// decrease corresponding sufficient statistics
+				this.Wt.Decr(word, k, uint32(1))
+				this.Dt.Decr(doc, k, uint32(1))
+				this.Dtm.Decr(doc, k, uint32(1))
+				this.Wts.Decr(k, uint32(0), uint32(1))
+				this.tree.Set(k, this.wordPart(word, k))
+
+				// compute the sparse document part
+				docSum := float32(0.0)
+				for idx, _ := range this.Dtm.Data[doc] {
+					tid, count := this.Dtm.Get(doc, idx)
+					docPart[tid] = float32(count) * this.tree.Get(tid)
+					docSum += docPart[tid]
+				}
+
+				// resample the topic
+				u := this.rng.Float32() * (docSum + this.Alpha*this.tree.Sum())
+				if u < docSum { // sparse document part
+					cumsum := float32(0.0)
+					for idx, _ := range this.Dtm.Data[doc] {
+						tid, _ := this.Dtm.Get(doc, idx)
+						cumsum += docPart[tid]
+						k = tid
+						if cumsum >= u {
+							break
+						}
+					}
+				} else { // dense part kept in the tree
+					k = this.tree.Sample((u - docSum) / this.Alpha)
+				}
+
+				// increase corresponding sufficient statistics
+				this.Wt.Incr(word, k, uint32(1))
+				this.Dt.Incr(doc, k, uint32(1))
+				this.Dtm.Incr(doc, k, uint32(1))
+				this.Wts.Incr(k, uint32(0), uint32(1))
+				this.tree.Set(k, this.wordPart(word, k))
+				this.Dwt[dw] = k
+			}
+		}
+	}
+}
+
+func (this *FTreeLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	// create sstables
+	this.Wt = sstable.NewUint32Matrix(dat.VocabSize, this.TopicNum)
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init()
+	this.initTables()
+
+	this.ResampleTopics(iter)
+}
+
+// infer topics on new documents
+func (this *FTreeLDA) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.Wts == nil {
+		log.Fatal("Wt or Wts is not initialized, maybe model is not loaded")
+	}
+	// Wt, Wts should be initialized when model was loaded
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init()
+	this.initTables()
+
+	this.ResampleTopics(iter)
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestFTreeLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewFTreeLDA(uint32(4), float32(0.1), float32(0.01)).(*FTreeLDA)
+	m.Seed(1)
+	m.Train(dat, 10)
+
+	assertCounts(t, dat, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertDocWordTopics(t, dat, m.TopicNum, m.Wt, m.Dt, m.Dwt)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	// the tree is kept exact during the pass of the last word
+	last := dat.VocabSize - uint32(1)
+	for k := uint32(0); k < m.TopicNum; k += 1 {
+		assert.InDelta(t, m.wordPart(last, k), m.tree.Get(k), 1e-6)
+	}
+
+	m.Infer(dat, 5)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestFTreeLDAPosterior(t *testing.T) {
+	dat, wt, wts := newTwoTokenCorpus()
+	m := NewFTreeLDA(uint32(3), float32(0.5), float32(0.1)).(*FTreeLDA)
+	m.Seed(1)
+	posterior := twoTokenPosterior(m.Alpha, m.Beta, wt, wts)
+	m.Wt, m.Wts = wt, wts
+	m.Infer(dat, 1)
+
+	assertTwoTokenPosterior(t, posterior, 200000, func() { m.ResampleTopics(1) },
+		func() map[sstable.DocWord]uint32 { return m.Dwt })
+}
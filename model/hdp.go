@@ -0,0 +1,460 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("hdp", NewHDP)
+}
+
+// HDP is the hierarchical Dirichlet process topic model trained by
+// the direct assignment gibbs sampler of Teh et al. (2006). Each
+// document draws its topic mixture from DP(alpha, G0) and the shared
+// G0 ~ DP(gamma, H) is represented by the global topic weights, so
+// the number of topics grows when a word picks the unseen topic and
+// shrinks when a topic loses its last word. TopicNum only sets the
+// number of initial topics and holds the number of learned topics
+// after training.
+type HDP struct {
+	*ldaBase
+	Gamma   float64   // top level concentration
+	Weights []float64 // global weights of topics
+	WeightU float64   // global weight of all unseen topics
+
+	// topic slots used during sampling, a slot is recycled after
+	// its topic becomes empty
+	nwk  [][]uint32          // word counts of each slot
+	nk   []uint32            // token counts of each slot
+	ndk  map[uint32][]uint32 // slot counts of each document
+	free []uint32            // empty slots
+}
+
+// NewHDP creates a HDP topic model instance, gamma can be changed by
+// SetHyperParam and topicNum is the number of initial topics
+func NewHDP(topicNum uint32, alpha float32, beta float32) Model {
+	return &HDP{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+		Gamma:   1.0,
+	}
+}
+
+// set gamma of the model
+func (this *HDP) SetHyperParam(name string, value float64) error {
+	switch name {
+	case "gamma":
+		if value <= 0 {
+			return fmt.Errorf("gamma should be positive: %f", value)
+		}
+		this.Gamma = value
+	default:
+		return fmt.Errorf("unknown hyperparameter of hdp: %s", name)
+	}
+	return nil
+}
+
+// create a topic in an empty slot and break a piece off the weight
+// of unseen topics for it
+func (this *HDP) newTopic() uint32 {
+	var k uint32
+	if len(this.free) > 0 {
+		k = this.free[len(this.free)-1]
+		this.free = this.free[:len(this.free)-1]
+	} else {
+		k = uint32(len(this.nk))
+		this.nwk = append(this.nwk, make([]uint32, this.Data.VocabSize))
+		this.nk = append(this.nk, uint32(0))
+		this.Weights = append(this.Weights, 0.0)
+	}
+	b := sampleBeta(this.rng, 1.0, this.Gamma)
+	this.Weights[k] = b * this.WeightU
+	this.WeightU = (1.0 - b) * this.WeightU
+	return k
+}
+
+// get the count of slot k in document doc
+func (this *HDP) docCount(doc, k uint32) uint32 {
+	if k >= uint32(len(this.ndk[doc])) {
+		return 0
+	}
+	return this.ndk[doc][k]
+}
+
+// add word w of document doc to slot k
+func (this *HDP) incr(doc, w, k uint32) {
+	for uint32(len(this.ndk[doc])) <= k {
+		this.ndk[doc] = append(this.ndk[doc], uint32(0))
+	}
+	this.nwk[k][w] += 1
+	this.nk[k] += 1
+	this.ndk[doc][k] += 1
+}
+
+// remove word w of document doc from slot k, the slot is freed and its
+// weight goes back to the unseen topics if it becomes empty
+func (this *HDP) decr(doc, w, k uint32) {
+	this.nwk[k][w] -= 1
+	this.nk[k] -= 1
+	this.ndk[doc][k] -= 1
+	if this.nk[k] == 0 {
+		this.WeightU += this.Weights[k]
+		this.Weights[k] = 0.0
+		this.free = append(this.free, k)
+	}
+}
+
+// randomly assign the initial topics to words
+func (this *HDP) Init() {
+	this.nwk = nil
+	this.nk = nil
+	this.free = nil
+	this.Weights = nil
+	this.WeightU = 1.0
+	this.ndk = make(map[uint32][]uint32)
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		this.newTopic()
+	}
+
+	dw := sstable.DocWord{}
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		for i, w := range corpus.ExpandWords(wcs) {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			this.incr(doc, w, k)
+			dw.DocId = doc
+			dw.WordIdx = uint32(i)
+			this.Dwt[dw] = k
+		}
+	}
+	// initial topics which got no words are dropped
+	for k, n := range this.nk {
+		if n == 0 {
+			this.WeightU += this.Weights[k]
+			this.Weights[k] = 0.0
+			this.free = append(this.free, uint32(k))
+		}
+	}
+}
+
+// sample the table counts of each document by the Antoniak
+// distribution and then the global topic weights given table counts
+func (this *HDP) sampleWeights() {
+	params := make([]float64, 0, len(this.nk)+1)
+	slots := make([]int, 0, len(this.nk))
+	tables := make([]float64, len(this.nk))
+	for _, doc := range this.Data.DocIds() {
+		counts := this.ndk[doc]
+		for k, n := range counts {
+			if n == 0 {
+				continue
+			}
+			ab := float64(this.Alpha) * this.Weights[k]
+			for j := uint32(0); j < n; j += 1 {
+				if this.rng.Float64()*(ab+float64(j)) < ab {
+					tables[k] += 1.0
+				}
+			}
+		}
+	}
+	for k, n := range this.nk {
+		if n > 0 {
+			params = append(params, tables[k])
+			slots = append(slots, k)
+		}
+	}
+	params = append(params, this.Gamma)
+
+	weights := make([]float64, len(params))
+	sampleDirichlet(this.rng, params, weights)
+	for i, k := range slots {
+		this.Weights[k] = weights[i]
+	}
+	this.WeightU = weights[len(weights)-1]
+}
+
+// number of topics with at least one word
+func (this *HDP) activeTopics() int {
+	return len(this.nk) - len(this.free)
+}
+
+func (this *HDP) ResampleTopics(iter int) {
+	dw := sstable.DocWord{}
+	var cumsum []float32
+	betaSum := this.Beta * float32(this.Data.VocabSize)
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if iterIdx%10 == 0 {
+			log.Infof("iter %5d, topics %d", iterIdx, this.activeTopics())
+		}
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			for i, w := range corpus.ExpandWords(wcs) {
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+				this.decr(doc, w, k)
+
+				// existing topics followed by the unseen topic
+				slots := uint32(len(this.nk))
+				if uint32(len(cumsum)) < slots+1 {
+					cumsum = make([]float32, 2*slots+1)
+				}
+				total := float32(0.0)
+				for kidx := uint32(0); kidx < slots; kidx += 1 {
+					if this.nk[kidx] > 0 {
+						total += (float32(this.docCount(doc, kidx)) +
+							this.Alpha*float32(this.Weights[kidx])) *
+							(float32(this.nwk[kidx][w]) + this.Beta) /
+							(float32(this.nk[kidx]) + betaSum)
+					}
+					cumsum[kidx] = total
+				}
+				total += this.Alpha * float32(this.WeightU) /
+					float32(this.Data.VocabSize)
+				cumsum[slots] = total
+
+				u := this.rng.Float32() * total
+				k = slots
+				for kidx := uint32(0); kidx < slots; kidx += 1 {
+					if this.nk[kidx] > 0 && u < cumsum[kidx] {
+						k = kidx
+						break
+					}
+				}
+				if k == slots {
+					k = this.newTopic()
+				}
+
+				this.incr(doc, w, k)
+				this.Dwt[dw] = k
+			}
+		}
+		this.sampleWeights()
+	}
+	log.Infof("number of topics %d", this.activeTopics())
+}
+
+// map the active slots to dense topic ids and fill the count tables,
+// TopicNum becomes the number of learned topics
+func (this *HDP) compact() {
+	slots := make([]uint32, 0, len(this.nk))
+	for k, n := range this.nk {
+		if n > 0 {
+			slots = append(slots, uint32(k))
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+	topicId := make(map[uint32]uint32)
+	weights := make([]float64, len(slots))
+	for i, k := range slots {
+		topicId[k] = uint32(i)
+		weights[i] = this.Weights[k]
+	}
+
+	this.TopicNum = uint32(len(slots))
+	this.Weights = weights
+	this.Wt = sstable.NewUint32Matrix(this.Data.VocabSize, this.TopicNum)
+	this.Dt = sstable.NewUint32Matrix(this.Data.DocNum, this.TopicNum)
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	for i, k := range slots {
+		for w, n := range this.nwk[k] {
+			if n > 0 {
+				this.Wt.Set(uint32(w), uint32(i), n)
+			}
+		}
+		this.Wts.Set(uint32(i), uint32(0), this.nk[k])
+	}
+	for doc, counts := range this.ndk {
+		for k, n := range counts {
+			if n > 0 {
+				this.Dt.Set(doc, topicId[uint32(k)], n)
+			}
+		}
+	}
+	for dw, k := range this.Dwt {
+		this.Dwt[dw] = topicId[k]
+	}
+
+	// release sampling state
+	this.nwk = nil
+	this.nk = nil
+	this.ndk = nil
+	this.free = nil
+}
+
+func (this *HDP) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.TopicNum == 0 {
+		this.TopicNum = 1
+	}
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init topics
+	this.Init()
+
+	this.ResampleTopics(iter)
+	this.compact()
+}
+
+// infer topics on new documents, the learned topics and their global
+// weights are held fixed and no new topic is created
+func (this *HDP) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.Wts == nil || this.Weights == nil {
+		log.Fatal("Wt, Wts or Weights is not initialized, maybe model is not loaded")
+	}
+	vocabSize, _ := this.Wt.Shape()
+	betaSum := this.Beta * float32(vocabSize)
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	dw := sstable.DocWord{}
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		for i, _ := range corpus.ExpandWords(wcs) {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			this.Dt.Incr(doc, k, uint32(1))
+			dw.DocId = doc
+			dw.WordIdx = uint32(i)
+			this.Dwt[dw] = k
+		}
+	}
+
+	cumsum := make([]float32, this.TopicNum)
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			for i, w := range corpus.ExpandWords(wcs) {
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+				this.Dt.Decr(doc, k, uint32(1))
+
+				total := float32(0.0)
+				for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+					wordCount := uint32(0)
+					if w < vocabSize {
+						wordCount = this.Wt.Get(w, kidx)
+					}
+					total += (float32(this.Dt.Get(doc, kidx)) +
+						this.Alpha*float32(this.Weights[kidx])) *
+						(float32(wordCount) + this.Beta) /
+						(float32(this.Wts.Get(kidx, uint32(0))) + betaSum)
+					cumsum[kidx] = total
+				}
+				u := this.rng.Float32() * total
+				for k = 0; k < this.TopicNum-1; k += 1 {
+					if u < cumsum[k] {
+						break
+					}
+				}
+
+				this.Dt.Incr(doc, k, uint32(1))
+				this.Dwt[dw] = k
+			}
+		}
+	}
+}
+
+// compute the posterior point estimation of document-topic mixture
+// alpha * global weights (DP prior) + data -> theta
+func (this *HDP) Theta() *sstable.Float32Matrix {
+	theta := sstable.NewFloat32Matrix(this.Data.DocNum, this.TopicNum)
+	weightSum := float32(0.0)
+	for _, weight := range this.Weights {
+		weightSum += float32(weight)
+	}
+
+	for d := uint32(0); d < this.Data.DocNum; d += 1 {
+		sum := sstable.Uint32VectorSum(this.Dt.GetRow(d))
+
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			result := (float32(this.Dt.Get(d, k)) +
+				this.Alpha*float32(this.Weights[k])) /
+				(float32(sum) + this.Alpha*weightSum)
+			theta.Set(d, k, result)
+		}
+	}
+
+	return theta
+}
+
+// get the document topic mixture hyperparameters alpha * global
+// weights of the learned topics
+func (this *HDP) GetAlphas() []float32 {
+	alphas := make([]float32, this.TopicNum)
+	for k, weight := range this.Weights {
+		alphas[k] = this.Alpha * float32(weight)
+	}
+	return alphas
+}
+
+// serialize document-topic distribution, rows are written with the
+// document keys of the corpus
+func (this *HDP) SaveTheta(fn string) error {
+	theta := this.Theta()
+	if err := sstable.Float32SerializeRows(theta, this.Data.DocKeys(), fn); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize word-topic matrix, the global topic weights are saved in
+// another file with suffix .weight and a stale .hyper is removed
+func (this *HDP) SaveWordTopic(fn string) error {
+	if err := sstable.Uint32Serialize(this.Wt, fn); err != nil {
+		return err
+	}
+	weights := sstable.NewFloat32Matrix(this.TopicNum, uint32(1))
+	for k, weight := range this.Weights {
+		weights.Set(uint32(k), uint32(0), float32(weight))
+	}
+	if err := sstable.Float32Serialize(weights, fn+".weight"); err != nil {
+		return err
+	}
+	return removeHyperParams(fn)
+}
+
+// deserialize word-topic matrix and global topic weights, the number
+// of topics is taken from the loaded model
+func (this *HDP) LoadWordTopic(fn string) error {
+	v, err := sstable.Uint32Deserialize(fn)
+	if err != nil {
+		return err
+	}
+	vocab, topicNum := v.Shape()
+	this.Wt = v
+	this.TopicNum = topicNum
+	// init WordTopicSum table
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	for r := uint32(0); r < vocab; r += 1 {
+		for t := uint32(0); t < topicNum; t += 1 {
+			this.Wts.Incr(t, uint32(0), this.Wt.Get(r, t))
+		}
+	}
+	weights, err := sstable.Float32Deserialize(fn + ".weight")
+	if err != nil {
+		return err
+	}
+	if topicNum, _ := weights.Shape(); topicNum != this.TopicNum {
+		return fmt.Errorf("%d topic weights found for %d topics",
+			topicNum, this.TopicNum)
+	}
+	this.Weights = make([]float64, this.TopicNum)
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		this.Weights[k] = float64(weights.Get(k, uint32(0)))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestHDP(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewHDP(uint32(2), float32(0.5), float32(0.01)).(*HDP)
+	m.Seed(1)
+	m.Train(dat, 10)
+
+	assert.True(t, m.TopicNum > 0)
+	assert.Equal(t, int(m.TopicNum), len(m.Weights))
+	assertCounts(t, dat, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertDocWordTopics(t, dat, m.TopicNum, m.Wt, m.Dt, m.Dwt)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	topicNum := m.TopicNum
+	m.Infer(dat, 5)
+	assert.Equal(t, topicNum, m.TopicNum)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestHDPSlots(t *testing.T) {
+	// start from one topic so that the sampler has to create topics
+	m := NewHDP(uint32(1), float32(0.5), float32(0.01)).(*HDP)
+	m.Seed(1)
+	m.Data = newTestCorpus()
+	m.Dwt = make(map[sstable.DocWord]uint32)
+	m.Init()
+
+	for iterIdx := 0; iterIdx < 5; iterIdx += 1 {
+		m.ResampleTopics(1)
+
+		// free slots are exactly the empty ones and carry no weight
+		free := make(map[uint32]bool)
+		for _, k := range m.free {
+			assert.False(t, free[k])
+			free[k] = true
+		}
+		sum := m.WeightU
+		for k, n := range m.nk {
+			assert.Equal(t, n == 0, free[uint32(k)])
+			if n == 0 {
+				assert.Equal(t, 0.0, m.Weights[k])
+			}
+			sum += m.Weights[k]
+		}
+		assert.InDelta(t, 1.0, sum, 1e-6)
+
+		// slot counts are the marginals of the assignments
+		for dw, k := range m.Dwt {
+			assert.True(t, m.ndk[dw.DocId][k] > 0)
+		}
+	}
+	assert.True(t, m.activeTopics() > 1)
+
+	m.compact()
+	assert.Equal(t, uint32(len(m.Weights)), m.TopicNum)
+	assertCounts(t, m.Data, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertDocWordTopics(t, m.Data, m.TopicNum, m.Wt, m.Dt, m.Dwt)
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+// the smallest value a learned hyperparameter can take
+const minHyperParam = 1e-5
+
+// compute sum_n hist[n] * (digamma(n+x) - digamma(x)), the digamma
+// differences are accumulated by the recurrence 1/(x+n-1) so the cost
+// is linear in the length of the histogram
+func digammaDiffSum(hist []uint32, x float64) float64 {
+	sum, diff := 0.0, 0.0
+	for n := 1; n < len(hist); n += 1 {
+		diff += 1.0 / (x + float64(n-1))
+		sum += float64(hist[n]) * diff
+	}
+	return sum
+}
+
+// update the asymmetric document topic mixture hyperparameter by the
+// fixed point iteration of Minka (2000) using the histogram trick of
+// Wallach (2008), topicHist[k][n] is the number of documents having n
+// tokens of topic k and lengthHist[n] is the number of documents
+// having n tokens
+func optimizeAlpha(alphas []float32, topicHist [][]uint32, lengthHist []uint32, iter int) {
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		alphaSum := 0.0
+		for _, a := range alphas {
+			alphaSum += float64(a)
+		}
+		denom := digammaDiffSum(lengthHist, alphaSum)
+		if denom <= 0 {
+			return
+		}
+		for k, a := range alphas {
+			val := float64(a) * digammaDiffSum(topicHist[k], float64(a)) / denom
+			if val < minHyperParam {
+				val = minHyperParam
+			}
+			alphas[k] = float32(val)
+		}
+	}
+}
+
+// update the symmetric topic word mixture hyperparameter by the fixed
+// point iteration, countHist[n] is the number of (word, topic) pairs
+// having n tokens and sumHist[n] is the number of topics having n
+// tokens
+func optimizeBeta(beta float32, vocabSize uint32, countHist []uint32,
+	sumHist []uint32, iter int) float32 {
+	val := float64(beta)
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		denom := float64(vocabSize) * digammaDiffSum(sumHist, val*float64(vocabSize))
+		if denom <= 0 {
+			break
+		}
+		val = val * digammaDiffSum(countHist, val) / denom
+		if val < minHyperParam {
+			val = minHyperParam
+		}
+	}
+	return float32(val)
+}
+
+// add one count to the histogram, the histogram grows as needed
+func histogramAdd(hist []uint32, n uint32) []uint32 {
+	for uint32(len(hist)) <= n {
+		hist = append(hist, 0)
+	}
+	hist[n] += 1
+	return hist
+}
+
+// compute the sum of document topic mixture hyperparameters
+func (this *ldaBase) alphaSum() float32 {
+	return sstable.Float32VectorSum(this.Alphas)
+}
+
+// get a copy of the document topic mixture hyperparameters
+func (this *ldaBase) GetAlphas() []float32 {
+	alphas := make([]float32, this.TopicNum)
+	copy(alphas, this.Alphas)
+	return alphas
+}
+
+// learn alpha from the doc-topic counts and beta from the histogram of
+// word-topic counts, the word-topic counts are passed by caller since
+// some samplers keep them in other tables than Wt
+func (this *ldaBase) updateHyperParams(countHist []uint32) {
+	topicHist := make([][]uint32, this.TopicNum)
+	var lengthHist []uint32
+	for doc, _ := range this.Data.Docs {
+		length := uint32(0)
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			cnt := this.Dt.Get(doc, k)
+			topicHist[k] = histogramAdd(topicHist[k], cnt)
+			length += cnt
+		}
+		lengthHist = histogramAdd(lengthHist, length)
+	}
+	optimizeAlpha(this.Alphas, topicHist, lengthHist, 20)
+
+	var sumHist []uint32
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		sumHist = histogramAdd(sumHist, this.Wts.Get(k, uint32(0)))
+	}
+	this.Beta = optimizeBeta(this.Beta, this.Data.VocabSize, countHist, sumHist, 20)
+
+	log.Infof("alpha sum %f, beta %f", this.alphaSum(), this.Beta)
+}
+
+// learn hyperparameters from the current counts
+func (this *ldaBase) OptimizeHyperParams() {
+	var countHist []uint32
+	for w := uint32(0); w < this.Data.VocabSize; w += 1 {
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			if cnt := this.Wt.Get(w, k); cnt > 0 {
+				countHist = histogramAdd(countHist, cnt)
+			}
+		}
+	}
+	this.updateHyperParams(countHist)
+}
+
+// set optimize_interval of the model, hyperparameters are learned
+// every optimize_interval iterations of training if it is positive
+func (this *LDA) SetHyperParam(name string, value float64) error {
+	switch name {
+	case "optimize_interval":
+		if value < 0 {
+			return fmt.Errorf("optimize_interval should be non-negative: %f", value)
+		}
+		this.OptimizeInterval = int(value)
+	default:
+		return fmt.Errorf("unknown hyperparameter of lda: %s", name)
+	}
+	return nil
+}
+
+// serialize hyperparameters, the first TopicNum rows are alpha of each
+// topic and the last row is beta
+func (this *ldaBase) saveHyperParams(fn string) error {
+	m := sstable.NewFloat32Matrix(this.TopicNum+uint32(1), uint32(1))
+	for k, a := range this.Alphas {
+		m.Set(uint32(k), uint32(0), a)
+	}
+	m.Set(this.TopicNum, uint32(0), this.Beta)
+	return sstable.Float32Serialize(m, fn)
+}
+
+// remove the hyperparameter file of word-topic table fn, models which
+// do not save hyperparameters call it when saving the table so that a
+// file left in the same path by another model is not loaded with it
+func removeHyperParams(fn string) error {
+	if err := os.Remove(fn + ".hyper"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// deserialize hyperparameters, the hyperparameters given to the
+// constructor are kept if the file does not exist
+func (this *ldaBase) loadHyperParams(fn string) error {
+	if _, err := os.Stat(fn); os.IsNotExist(err) {
+		return nil
+	}
+	m, err := sstable.Float32Deserialize(fn)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("hyperparameter file %s is empty", fn)
+	}
+	if row, _ := m.Shape(); row != this.TopicNum+uint32(1) {
+		return fmt.Errorf("hyperparameters of %d topics, %d expected", row-1, this.TopicNum)
+	}
+	for k, _ := range this.Alphas {
+		this.Alphas[k] = m.Get(uint32(k), uint32(0))
+	}
+	this.Beta = m.Get(this.TopicNum, uint32(0))
+	return nil
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimizeIntervalSupport(t *testing.T) {
+	assert.Nil(t, SetHyperParams(NewLDA(uint32(4), 0.1, 0.01), "optimize_interval=10"))
+	assert.Nil(t, SetHyperParams(NewSparseLDA(uint32(4), 0.1, 0.01), "optimize_interval=10"))
+	for _, ctor := range []ModelCtor{NewAliasLDA, NewFTreeLDA, NewWarpLDA,
+		NewLightLDA, NewAuthorTopic, NewADLDA} {
+		_, ok := ctor(uint32(4), 0.1, 0.01).(HyperParamSetter)
+		assert.False(t, ok)
+	}
+	for _, ctor := range []ModelCtor{NewPYPLDA, NewHDP} {
+		assert.NotNil(t, SetHyperParams(ctor(uint32(4), 0.1, 0.01), "optimize_interval=10"))
+	}
+}
+
+func TestStaleHyperParams(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "model.wt")
+	dat := newTestCorpus()
+
+	m := NewLDA(uint32(4), float32(0.1), float32(0.01)).(*LDA)
+	assert.Nil(t, SetHyperParams(m, "optimize_interval=2"))
+	m.Seed(1)
+	m.Train(dat, 6)
+	assert.Nil(t, m.SaveWordTopic(fn))
+	_, err := os.Stat(fn + ".hyper")
+	assert.Nil(t, err)
+
+	// a model without hyperparameters removes the file of lda
+	o := NewOnlineLDA(uint32(4), float32(0.1), float32(0.01))
+	o.Train(dat, 1)
+	assert.Nil(t, o.SaveWordTopic(fn))
+	_, err = os.Stat(fn + ".hyper")
+	assert.True(t, os.IsNotExist(err))
+}
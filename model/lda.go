@@ -15,11 +15,37 @@ func init() {
 	Register("lda", NewLDA)
 }
 
+// LDA is the collapsed gibbs sampler with checkpoints, training
+// monitor, posterior averaging and hyperparameter learning. The other
+// gibbs samplers embed ldaBase instead, so they only implement the
+// interfaces of the training options they act on.
 type LDA struct {
-	Alpha    float32 // document topic mixture hyperparameter
-	Beta     float32 // topic word mixture hyperparameter
+	*ldaBase
+}
+
+// ldaBase is the state and the collapsed gibbs sampler shared by the
+// samplers embedding it
+type ldaBase struct {
+	Alpha    float32   // document topic mixture hyperparameter
+	Alphas   []float32 // asymmetric document topic mixture hyperparameter
+	Beta     float32   // topic word mixture hyperparameter
 	TopicNum uint32
 
+	OptimizeInterval   int    // iterations between hyperparameter updates
+	CheckpointInterval int    // iterations between checkpoints
+	CheckpointFile     string // file of checkpoints
+	Iteration          int    // number of finished training iterations
+	BurnIn             int    // iterations before the first sample of phi and theta
+	Thin               int    // iterations between samples of phi and theta
+	training           bool   // whether sampling is called by training
+
+	monitor  *Monitor   // training metrics recorder
+	phiSum   *sampleSum // sum of sampled phi after burn-in
+	thetaSum *sampleSum // sum of sampled theta after burn-in
+
+	source *rngSource
+	rng    *rand.Rand
+
 	Data *corpus.Corpus // for convenience
 
 	Wt  *sstable.Uint32Matrix      // word-topic count table
@@ -31,20 +57,40 @@ type LDA struct {
 // New creates a LDA instance with collapsed gibbs sampler
 func NewLDA(topicNum uint32, alpha float32, beta float32) Model {
 	return &LDA{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+	}
+}
+
+// create the state shared by the gibbs samplers
+func newLDABase(topicNum uint32, alpha float32, beta float32) *ldaBase {
+	alphas := make([]float32, topicNum)
+	for k, _ := range alphas {
+		alphas[k] = alpha
+	}
+	source := newRNGSource(time.Now().UnixNano())
+	return &ldaBase{
 		Alpha:    alpha,
+		Alphas:   alphas,
 		Beta:     beta,
 		TopicNum: topicNum,
+		source:   source,
+		rng:      rand.New(source),
 	}
 }
 
-func (this *LDA) Init() {
+// seed the random source of the sampler
+func (this *ldaBase) Seed(seed int64) {
+	this.source.Seed(seed)
+}
+
+func (this *ldaBase) Init() {
 	// randomly assign topic to word
-	rand.Seed(time.Now().Unix())
 	dw := sstable.DocWord{}
-	for doc, wcs := range this.Data.Docs {
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
 		for i, w := range corpus.ExpandWords(wcs) {
 			// sample word topic
-			k := uint32(rand.Int31n(int32(this.TopicNum)))
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
 
 			// update sufficient statistics
 			this.Wt.Incr(w, k, uint32(1))
@@ -59,7 +105,7 @@ func (this *LDA) Init() {
 	}
 }
 
-func (this *LDA) ResampleTopics(iter int) {
+func (this *ldaBase) ResampleTopics(iter int) {
 	dw := sstable.DocWord{}
 	cumsum := make([]float32, this.TopicNum)
 
@@ -69,13 +115,20 @@ func (this *LDA) ResampleTopics(iter int) {
 				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
 			}
 		}
+		if this.training && this.OptimizeInterval > 0 &&
+			this.Iteration > 0 && this.Iteration%this.OptimizeInterval == 0 {
+			this.OptimizeHyperParams()
+		}
 		// collapsed gibbs sampling
-		for doc, wcs := range this.Data.Docs {
+		tokens, changes := uint64(0), uint64(0)
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
 			for i, w := range corpus.ExpandWords(wcs) {
 				// get the current topic of word w
 				dw.DocId = doc
 				dw.WordIdx = uint32(i)
 				k := this.Dwt[dw]
+				oldK := k
 
 				// decrease corresponding sufficient statistics
 				this.Wt.Decr(w, k, uint32(1))
@@ -84,7 +137,7 @@ func (this *LDA) ResampleTopics(iter int) {
 
 				// resample the topic
 				for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
-					docPart := this.Alpha + float32(this.Dt.Get(doc, kidx))
+					docPart := this.Alphas[kidx] + float32(this.Dt.Get(doc, kidx))
 					wordPart := (this.Beta + float32(this.Wt.Get(w, kidx))) /
 						(float32(this.Wts.Get(kidx, uint32(0))) +
 							this.Beta*float32(this.Data.VocabSize))
@@ -94,7 +147,7 @@ func (this *LDA) ResampleTopics(iter int) {
 						cumsum[kidx] = cumsum[kidx-1] + docPart*wordPart
 					}
 				}
-				u := rand.Float32() * cumsum[this.TopicNum-1]
+				u := this.rng.Float32() * cumsum[this.TopicNum-1]
 				for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
 					if u < cumsum[kidx] {
 						k = kidx
@@ -107,12 +160,32 @@ func (this *LDA) ResampleTopics(iter int) {
 				this.Dt.Incr(doc, k, uint32(1))
 				this.Wts.Incr(k, uint32(0), uint32(1))
 				this.Dwt[dw] = k
+
+				tokens += 1
+				if k != oldK {
+					changes += 1
+				}
+			}
+		}
+
+		if this.training {
+			this.Iteration += 1
+			// the checkpoint holds the sample of the same iteration
+			if this.sampleDue() {
+				this.takeSample(this.statePhi(), this.stateTheta())
+			}
+			if this.checkpointDue() {
+				this.checkpoint(this.Wt)
+			}
+			if this.monitor != nil && this.monitor.Record(this.Iteration,
+				tokens, changes, this.Likelihood, this.Alphas, this.Beta) {
+				break
 			}
 		}
 	}
 }
 
-func (this *LDA) Train(dat *corpus.Corpus, iter int) {
+func (this *ldaBase) Train(dat *corpus.Corpus, iter int) {
 	if dat == nil {
 		log.Fatal("corpus is nil")
 	}
@@ -126,11 +199,16 @@ func (this *LDA) Train(dat *corpus.Corpus, iter int) {
 	// randomly init sstables
 	this.Init()
 
+	this.Iteration = 0
+	this.phiSum, this.thetaSum = nil, nil
+	this.training = true
 	this.ResampleTopics(iter)
+	this.training = false
+	this.checkSamples()
 }
 
 // infer topics on new documents
-func (this *LDA) Infer(dat *corpus.Corpus, iter int) {
+func (this *ldaBase) Infer(dat *corpus.Corpus, iter int) {
 	if dat == nil {
 		log.Fatal("corpus is nil")
 	}
@@ -138,6 +216,7 @@ func (this *LDA) Infer(dat *corpus.Corpus, iter int) {
 		log.Fatal("Wt or Wts is not initialized, maybe model is not loaded")
 	}
 	// Wt, Wts should be initialized when model was loaded
+	this.thetaSum = nil
 	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
 	this.Dwt = make(map[sstable.DocWord]uint32)
 	this.Data = dat
@@ -148,17 +227,37 @@ func (this *LDA) Infer(dat *corpus.Corpus, iter int) {
 	this.ResampleTopics(iter)
 }
 
+// get the word-topic mixture averaged over samples, or the one of the
+// current state if no sample is taken
+func (this *ldaBase) Phi() *sstable.Float32Matrix {
+	if this.phiSum != nil {
+		return this.phiSum.mean()
+	}
+	return this.statePhi()
+}
+
+// get the document-topic mixture averaged over samples, or the one of
+// the current state if no sample is taken
+func (this *ldaBase) Theta() *sstable.Float32Matrix {
+	if this.thetaSum != nil {
+		return this.thetaSum.mean()
+	}
+	return this.stateTheta()
+}
+
 // compute the posterior point estimation of word-topic mixture
-// beta (Dirichlet prior) + data -> phi
-func (this *LDA) Phi() *sstable.Float32Matrix {
-	phi := sstable.NewFloat32Matrix(this.Data.VocabSize, this.TopicNum)
+// beta (Dirichlet prior) + data -> phi, the vocabulary is the one of
+// Wt so phi of a loaded model can be computed without corpus
+func (this *ldaBase) statePhi() *sstable.Float32Matrix {
+	vocabSize, _ := this.Wt.Shape()
+	phi := sstable.NewFloat32Matrix(vocabSize, this.TopicNum)
 
 	for k := uint32(0); k < this.TopicNum; k += 1 {
 		sum := sstable.Uint32VectorSum(this.Wt.GetCol(k))
 
-		for v := uint32(0); v < this.Data.VocabSize; v += 1 {
+		for v := uint32(0); v < vocabSize; v += 1 {
 			result := (float32(this.Wt.Get(v, k)) + this.Beta) /
-				(float32(sum) + float32(this.Data.VocabSize)*this.Beta)
+				(float32(sum) + float32(vocabSize)*this.Beta)
 			phi.Set(v, k, result)
 		}
 	}
@@ -168,15 +267,16 @@ func (this *LDA) Phi() *sstable.Float32Matrix {
 
 // compute the posterior point estimation of document-topic mixture
 // alpha (Dirichlet prior) + data -> theta
-func (this *LDA) Theta() *sstable.Float32Matrix {
+func (this *ldaBase) stateTheta() *sstable.Float32Matrix {
 	theta := sstable.NewFloat32Matrix(this.Data.DocNum, this.TopicNum)
+	alphaSum := this.alphaSum()
 
 	for d := uint32(0); d < this.Data.DocNum; d += 1 {
 		sum := sstable.Uint32VectorSum(this.Dt.GetRow(d))
 
 		for k := uint32(0); k < this.TopicNum; k += 1 {
-			result := (float32(this.Dt.Get(d, k)) + this.Alpha) /
-				(float32(sum) + float32(this.TopicNum)*this.Alpha)
+			result := (float32(this.Dt.Get(d, k)) + this.Alphas[k]) /
+				(float32(sum) + alphaSum)
 			theta.Set(d, k, result)
 		}
 	}
@@ -184,13 +284,17 @@ func (this *LDA) Theta() *sstable.Float32Matrix {
 	return theta
 }
 
-// compute the joint likelihood of corpus
-func (this *LDA) Likelihood() float64 {
-	phi := this.Phi()
-	theta := this.Theta()
+// compute the joint likelihood of corpus under the current state
+func (this *ldaBase) Likelihood() float64 {
+	return this.likelihood(this.statePhi(), this.stateTheta())
+}
 
+// compute the likelihood of corpus under word-topic mixture phi and
+// document-topic mixture theta
+func (this *ldaBase) likelihood(phi, theta *sstable.Float32Matrix) float64 {
 	sum := float64(0.0)
-	for doc, wcs := range this.Data.Docs {
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
 		for _, w := range corpus.ExpandWords(wcs) {
 			topicSum := float32(0.0)
 			for k := uint32(0); k < this.TopicNum; k += 1 {
@@ -204,7 +308,7 @@ func (this *LDA) Likelihood() float64 {
 }
 
 // serialize word-topic distribution
-func (this *LDA) SavePhi(fn string) error {
+func (this *ldaBase) SavePhi(fn string) error {
 	phi := this.Phi()
 	if err := sstable.Float32Serialize(phi, fn); err != nil {
 		return err
@@ -212,29 +316,37 @@ func (this *LDA) SavePhi(fn string) error {
 	return nil
 }
 
-// serialize document-topic distribution
-func (this *LDA) SaveTheta(fn string) error {
+// serialize document-topic distribution, rows are written with the
+// document keys of the corpus
+func (this *ldaBase) SaveTheta(fn string) error {
 	theta := this.Theta()
-	if err := sstable.Float32Serialize(theta, fn); err != nil {
+	if err := sstable.Float32SerializeRows(theta, this.Data.DocKeys(), fn); err != nil {
 		return err
 	}
 	return nil
 }
 
-// serialize word-topic matrix
-func (this *LDA) SaveWordTopic(fn string) error {
+// serialize word-topic matrix, the hyperparameters are saved in
+// another file with suffix .hyper
+func (this *ldaBase) SaveWordTopic(fn string) error {
 	if err := sstable.Uint32Serialize(this.Wt, fn); err != nil {
 		return err
 	}
+	if err := this.saveHyperParams(fn + ".hyper"); err != nil {
+		return err
+	}
 	return nil
 }
 
-// deserialize word-topic matrix
-func (this *LDA) LoadWordTopic(fn string) error {
+// deserialize word-topic matrix and hyperparameters
+func (this *ldaBase) LoadWordTopic(fn string) error {
 	v, err := sstable.Uint32Deserialize(fn)
 	if err != nil {
 		return err
 	}
+	if err := this.loadHyperParams(fn + ".hyper"); err != nil {
+		return err
+	}
 	this.Wt = v
 	// init WordTopicSum table
 	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
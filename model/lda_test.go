@@ -0,0 +1,33 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewLDA(uint32(4), float32(0.1), float32(0.01)).(*LDA)
+	m.Seed(1)
+	m.Train(dat, 10)
+
+	assertCounts(t, dat, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertDocWordTopics(t, dat, m.TopicNum, m.Wt, m.Dt, m.Dwt)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	m.Infer(dat, 5)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestLDAPosterior(t *testing.T) {
+	dat, wt, wts := newTwoTokenCorpus()
+	m := NewLDA(uint32(3), float32(0.5), float32(0.1)).(*LDA)
+	m.Seed(1)
+	posterior := twoTokenPosterior(m.Alpha, m.Beta, wt, wts)
+	m.Wt, m.Wts = wt, wts
+	m.Infer(dat, 1)
+
+	assertTwoTokenPosterior(t, posterior, 200000, func() { m.ResampleTopics(1) },
+		func() map[sstable.DocWord]uint32 { return m.Dwt })
+}
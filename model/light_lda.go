@@ -0,0 +1,173 @@
+package model
+
+import (
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("lightlda", NewLightLDA)
+}
+
+// LightLDA alternates two cheap Metropolis-Hastings proposals for each
+// token. The word proposal q(k) ~ (n_wk+beta)/(n_k+beta*V) is drawn
+// from a stale per-word alias table built from the Wt columns, and the
+// doc proposal q(k) ~ n_dk+alpha is drawn by picking the topic of a
+// random token in the document. Both proposals take O(1) time, so the
+// sampler trades more MH steps for a constant cost per token.
+type LightLDA struct {
+	*ldaBase
+	MHSteps uint32 // number of proposals per token, word and doc in turn
+
+	tables []*sstable.AliasTable // stale word alias tables
+	draws  []uint32              // samples drawn since table was built
+}
+
+// NewLightLDA creates a light lda instance with cycling word and
+// document proposals
+func NewLightLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &LightLDA{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+		MHSteps: uint32(2),
+	}
+}
+
+// the current topic-word part (n_wk+beta)/(n_k+beta*V) of the conditional
+func (this *LightLDA) wordPart(w, k uint32) float32 {
+	return (this.Beta + float32(this.Wt.Get(w, k))) /
+		(float32(this.Wts.Get(k, uint32(0))) +
+			this.Beta*float32(this.Data.VocabSize))
+}
+
+// get the alias table of word w, the table is rebuilt from current
+// counts if it is used for the first time or it has gone stale
+func (this *LightLDA) aliasTable(w uint32, weights []float32) *sstable.AliasTable {
+	if this.tables[w] == nil {
+		this.tables[w] = sstable.NewAliasTable(this.TopicNum)
+		this.draws[w] = this.TopicNum
+	}
+	if this.draws[w] >= this.TopicNum {
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			weights[k] = this.wordPart(w, k)
+		}
+		this.tables[w].Build(weights)
+		this.draws[w] = 0
+	}
+	return this.tables[w]
+}
+
+func (this *LightLDA) ResampleTopics(iter int) {
+	dw := sstable.DocWord{}
+	weights := make([]float32, this.TopicNum)
+	this.tables = make([]*sstable.AliasTable, this.Data.VocabSize)
+	this.draws = make([]uint32, this.Data.VocabSize)
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			words := corpus.ExpandWords(wcs)
+			length := float32(len(words))
+			for i, w := range words {
+				// get the current topic of word w
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+
+				// decrease corresponding sufficient statistics
+				this.Wt.Decr(w, k, uint32(1))
+				this.Dt.Decr(doc, k, uint32(1))
+				this.Wts.Decr(k, uint32(0), uint32(1))
+
+				s := k
+				for step := uint32(0); step < this.MHSteps; step += 1 {
+					var t uint32
+					var qs, qt float32
+					if step%2 == 0 { // word proposal
+						table := this.aliasTable(w, weights)
+						t = table.Sample(this.rng.Float32())
+						this.draws[w] += 1
+						qs, qt = table.Weight[s], table.Weight[t]
+					} else { // doc proposal
+						// drawn from the document with the current token
+						// at topic s, i.e. n_dk+alpha plus one for topic s,
+						// the extra count of s cancels in the ratio
+						if this.rng.Float32()*(length+float32(this.TopicNum)*this.Alpha) < length {
+							j := uint32(this.rng.Int63n(int64(len(words))))
+							if j == uint32(i) {
+								t = s
+							} else {
+								dw.WordIdx = j
+								t = this.Dwt[dw]
+								dw.WordIdx = uint32(i)
+							}
+						} else {
+							t = uint32(this.rng.Int31n(int32(this.TopicNum)))
+						}
+						qs = float32(this.Dt.Get(doc, s)) + this.Alpha
+						qt = float32(this.Dt.Get(doc, t)) + this.Alpha
+					}
+					if t == s {
+						continue
+					}
+
+					// acceptance ratio p(t)q(s) / p(s)q(t)
+					ps := (this.Alpha + float32(this.Dt.Get(doc, s))) * this.wordPart(w, s)
+					pt := (this.Alpha + float32(this.Dt.Get(doc, t))) * this.wordPart(w, t)
+					if this.rng.Float32()*ps*qt < pt*qs {
+						s = t
+					}
+				}
+				k = s
+
+				// increase corresponding sufficient statistics
+				this.Wt.Incr(w, k, uint32(1))
+				this.Dt.Incr(doc, k, uint32(1))
+				this.Wts.Incr(k, uint32(0), uint32(1))
+				this.Dwt[dw] = k
+			}
+		}
+	}
+}
+
+func (this *LightLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	// create sstables
+	this.Wt = sstable.NewUint32Matrix(dat.VocabSize, this.TopicNum)
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init()
+
+	this.ResampleTopics(iter)
+}
+
+// infer topics on new documents
+func (this *LightLDA) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.Wts == nil {
+		log.Fatal("Wt or Wts is not initialized, maybe model is not loaded")
+	}
+	// Wt, Wts should be initialized when model was loaded
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// randomly init sstables
+	this.Init()
+
+	this.ResampleTopics(iter)
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestLightLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewLightLDA(uint32(4), float32(0.1), float32(0.01)).(*LightLDA)
+	m.Seed(1)
+	m.Train(dat, 10)
+
+	assertCounts(t, dat, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertDocWordTopics(t, dat, m.TopicNum, m.Wt, m.Dt, m.Dwt)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	m.Infer(dat, 5)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestLightLDAPosterior(t *testing.T) {
+	// odd steps use the doc proposal, so three steps per token check
+	// the acceptance of both proposals
+	dat, wt, wts := newTwoTokenCorpus()
+	m := NewLightLDA(uint32(3), float32(0.5), float32(0.1)).(*LightLDA)
+	m.MHSteps = uint32(3)
+	m.Seed(1)
+	posterior := twoTokenPosterior(m.Alpha, m.Beta, wt, wts)
+	m.Wt, m.Wts = wt, wts
+	m.Infer(dat, 1)
+
+	assertTwoTokenPosterior(t, posterior, 200000, func() { m.ResampleTopics(1) },
+		func() map[sstable.DocWord]uint32 { return m.Dwt })
+}
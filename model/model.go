@@ -2,6 +2,8 @@ package model
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/bobonovski/gotm/corpus"
 	"github.com/bobonovski/gotm/sstable"
@@ -29,6 +31,107 @@ type Model interface {
 	LoadWordTopic(fn string) error
 }
 
+// models with hyperparameters other than alpha and beta should
+// implement this interface, the parameters are passed by name
+type HyperParamSetter interface {
+	SetHyperParam(name string, value float64) error
+}
+
+// models learning topic mixtures of authors should implement this
+// interface to serialize the author-topic distribution
+type AuthorTopicSaver interface {
+	SaveAuthorTopic(fn string) error
+}
+
+// models able to learn from minibatches of a document stream should
+// implement this interface, so the corpus never needs to fit in memory,
+// one call goes over one pass of the iterator
+type StreamTrainer interface {
+	TrainStream(it corpus.DocumentIterator) error
+}
+
+// models with an asymmetric document topic prior should implement
+// this interface, evaluation uses a symmetric alpha otherwise
+type AlphaGetter interface {
+	GetAlphas() []float32
+}
+
+// DocTopicPrior gets the document topic prior of the model, it is
+// symmetric alpha of topicNum topics if the model does not implement
+// AlphaGetter
+func DocTopicPrior(m Model, topicNum uint32, alpha float32) []float32 {
+	if g, ok := m.(AlphaGetter); ok {
+		return g.GetAlphas()
+	}
+	alphas := make([]float32, topicNum)
+	for k, _ := range alphas {
+		alphas[k] = alpha
+	}
+	return alphas
+}
+
+// gibbs samplers able to save their state during training and
+// continue training from it should implement this interface
+type Checkpointer interface {
+	// write checkpoint to fn every interval training iterations
+	SetCheckpoint(fn string, interval int)
+	// continue training on dat from checkpoint fn until iter
+	// iterations are finished in total
+	Resume(dat *corpus.Corpus, fn string, iter int) error
+}
+
+// models able to sample with multiple goroutines should implement
+// this interface to set the number of workers
+type WorkerSetter interface {
+	SetWorkers(n int) error
+}
+
+// models recording training metrics and stopping early on convergence
+// should implement this interface
+type MonitorSetter interface {
+	SetMonitor(m *Monitor)
+}
+
+// models averaging phi and theta over samples after burn-in should
+// implement this interface
+type SampleAverager interface {
+	SetAveraging(burnin, thin int) error
+}
+
+// models drawing random numbers from their own source should implement
+// this interface, training with the same seed and input gives the
+// same result
+type Seeder interface {
+	Seed(seed int64)
+}
+
+// SetHyperParams parses the comma separated name=value list and
+// passes the values to the model, it fails if the model does not
+// take extra hyperparameters
+func SetHyperParams(m Model, params string) error {
+	if params == "" {
+		return nil
+	}
+	setter, ok := m.(HyperParamSetter)
+	if !ok {
+		return fmt.Errorf("model does not take extra hyperparameters")
+	}
+	for _, kv := range strings.Split(params, ",") {
+		nv := strings.Split(kv, "=")
+		if len(nv) != 2 {
+			return fmt.Errorf("bad hyperparameter: %s", kv)
+		}
+		value, err := strconv.ParseFloat(nv[1], 64)
+		if err != nil {
+			return err
+		}
+		if err := setter.SetHyperParam(strings.TrimSpace(nv[0]), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // new LDA sampler should register itself using this function
 func Register(modelType string, m ModelCtor) {
 	constructors[modelType] = m
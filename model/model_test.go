@@ -0,0 +1,204 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+// create a corpus of two groups of documents using disjoint halves of
+// the vocabulary, so the samplers have some structure to find
+func newTestCorpus() *corpus.Corpus {
+	dat := &corpus.Corpus{}
+	for doc := uint32(0); doc < 20; doc += 1 {
+		var wcs []*corpus.WordCount
+		for w := uint32(0); w < 5; w += 1 {
+			wcs = append(wcs, &corpus.WordCount{
+				WordId: (doc%2)*5 + w,
+				Count:  doc%3 + w%2 + 1,
+			})
+		}
+		dat.AddDoc(doc, wcs)
+	}
+	dat.DocNum = 20
+	dat.VocabSize = 10
+	return dat
+}
+
+// create a corpus of documents mixing both halves of the vocabulary
+// of newTestCorpus, for inference on documents unseen in training
+func newHeldOutCorpus() *corpus.Corpus {
+	dat := &corpus.Corpus{}
+	for doc := uint32(0); doc < 6; doc += 1 {
+		var wcs []*corpus.WordCount
+		for w := doc % 3; w < 10; w += 3 {
+			wcs = append(wcs, &corpus.WordCount{WordId: w, Count: doc%2 + 1})
+		}
+		dat.AddDoc(doc, wcs)
+	}
+	dat.DocNum = 6
+	dat.VocabSize = 10
+	return dat
+}
+
+// count the tokens of each document and word in the corpus
+func tokenCounts(dat *corpus.Corpus) ([]uint32, []uint32) {
+	docLen := make([]uint32, dat.DocNum)
+	wordFreq := make([]uint32, dat.VocabSize)
+	for doc, wcs := range dat.Docs {
+		for _, w := range corpus.ExpandWords(wcs) {
+			docLen[doc] += 1
+			wordFreq[w] += 1
+		}
+	}
+	return docLen, wordFreq
+}
+
+// check the count tables are the marginals of one assignment of every
+// token: rows of Dt sum to document lengths, rows of Wt sum to word
+// frequencies and Wts is the column sum of Wt
+func assertCounts(t *testing.T, dat *corpus.Corpus, topicNum uint32,
+	wt, dt, wts *sstable.Uint32Matrix) {
+	docLen, wordFreq := tokenCounts(dat)
+	for doc := uint32(0); doc < dat.DocNum; doc += 1 {
+		assert.Equal(t, docLen[doc], sstable.Uint32VectorSum(dt.GetRow(doc)))
+	}
+	for w := uint32(0); w < dat.VocabSize; w += 1 {
+		assert.Equal(t, wordFreq[w], sstable.Uint32VectorSum(wt.GetRow(w)))
+	}
+	for k := uint32(0); k < topicNum; k += 1 {
+		assert.Equal(t, sstable.Uint32VectorSum(wt.GetCol(k)), wts.Get(k, uint32(0)))
+	}
+}
+
+// check every column of phi and every row of theta is a distribution
+func assertDistributions(t *testing.T, phi, theta *sstable.Float32Matrix) {
+	vocabSize, topicNum := phi.Shape()
+	for k := uint32(0); k < topicNum; k += 1 {
+		sum := float32(0.0)
+		for w := uint32(0); w < vocabSize; w += 1 {
+			sum += phi.Get(w, k)
+		}
+		assert.InDelta(t, 1.0, sum, 1e-4)
+	}
+	docNum, _ := theta.Shape()
+	for doc := uint32(0); doc < docNum; doc += 1 {
+		sum := float32(0.0)
+		for k := uint32(0); k < topicNum; k += 1 {
+			sum += theta.Get(doc, k)
+		}
+		assert.InDelta(t, 1.0, sum, 1e-4)
+	}
+}
+
+// check the count tables are the counts of the topic assignments,
+// topicOf gets the topic of the i-th token of document doc
+func assertAssignments(t *testing.T, dat *corpus.Corpus, topicNum uint32,
+	wt, dt *sstable.Uint32Matrix, topicOf func(doc, i uint32) uint32) {
+	nwk := sstable.NewUint32Matrix(dat.VocabSize, topicNum)
+	ndk := sstable.NewUint32Matrix(dat.DocNum, topicNum)
+	for doc, wcs := range dat.Docs {
+		for i, w := range corpus.ExpandWords(wcs) {
+			k := topicOf(doc, uint32(i))
+			assert.True(t, k < topicNum)
+			nwk.Incr(w, k, uint32(1))
+			ndk.Incr(doc, k, uint32(1))
+		}
+	}
+	assert.Equal(t, nwk, wt)
+	assert.Equal(t, ndk, dt)
+}
+
+// check the topic assignments of map dwt against the count tables
+func assertDocWordTopics(t *testing.T, dat *corpus.Corpus, topicNum uint32,
+	wt, dt *sstable.Uint32Matrix, dwt map[sstable.DocWord]uint32) {
+	assertAssignments(t, dat, topicNum, wt, dt, func(doc, i uint32) uint32 {
+		k, ok := dwt[sstable.DocWord{DocId: doc, WordIdx: i}]
+		assert.True(t, ok)
+		return k
+	})
+}
+
+// create a corpus of one document with two tokens of words 0 and 1,
+// and the word-topic counts of a vocabulary of 4 words over 3 topics
+// as if they were loaded from a trained model
+func newTwoTokenCorpus() (*corpus.Corpus, *sstable.Uint32Matrix, *sstable.Uint32Matrix) {
+	dat := &corpus.Corpus{}
+	dat.AddDoc(uint32(0), []*corpus.WordCount{
+		&corpus.WordCount{WordId: 0, Count: 1},
+		&corpus.WordCount{WordId: 1, Count: 1},
+	})
+	dat.DocNum = 1
+	dat.VocabSize = 4
+
+	counts := [][]uint32{{6, 1, 0}, {0, 2, 5}, {3, 0, 0}, {0, 0, 4}}
+	wt := sstable.NewUint32Matrix(uint32(4), uint32(3))
+	wts := sstable.NewUint32Matrix(uint32(3), uint32(1))
+	for w, row := range counts {
+		for k, n := range row {
+			wt.Set(uint32(w), uint32(k), n)
+			wts.Incr(uint32(k), uint32(0), n)
+		}
+	}
+	return dat, wt, wts
+}
+
+// compute the exact collapsed posterior of the topics of the two
+// tokens of newTwoTokenCorpus given the loaded word-topic counts
+func twoTokenPosterior(alpha, beta float32, wt, wts *sstable.Uint32Matrix) [][]float64 {
+	vocabSize, topicNum := wt.Shape()
+	betaSum := float64(beta) * float64(vocabSize)
+	posterior := make([][]float64, topicNum)
+	sum := 0.0
+	for a := uint32(0); a < topicNum; a += 1 {
+		posterior[a] = make([]float64, topicNum)
+		for b := uint32(0); b < topicNum; b += 1 {
+			same := 0.0
+			if a == b {
+				same = 1.0
+			}
+			p := float64(alpha) * (float64(wt.Get(0, a)) + float64(beta)) /
+				(float64(wts.Get(a, 0)) + betaSum)
+			p *= (float64(alpha) + same) * (float64(wt.Get(1, b)) + float64(beta)) /
+				(float64(wts.Get(b, 0)) + betaSum + same)
+			posterior[a][b] = p
+			sum += p
+		}
+	}
+	for a, _ := range posterior {
+		for b, _ := range posterior[a] {
+			posterior[a][b] /= sum
+		}
+	}
+	return posterior
+}
+
+// run sweep n times on newTwoTokenCorpus and check the frequencies of
+// the topic pairs of its two tokens against the exact posterior, dwt
+// gets the current topic assignments
+func assertTwoTokenPosterior(t *testing.T, posterior [][]float64, n int, sweep func(),
+	dwt func() map[sstable.DocWord]uint32) {
+	for i := 0; i < 100; i += 1 {
+		sweep()
+	}
+	freq := make([][]float64, len(posterior))
+	for a, _ := range freq {
+		freq[a] = make([]float64, len(posterior))
+	}
+	for i := 0; i < n; i += 1 {
+		sweep()
+		topics := dwt()
+		a := topics[sstable.DocWord{DocId: 0, WordIdx: 0}]
+		b := topics[sstable.DocWord{DocId: 0, WordIdx: 1}]
+		freq[a][b] += 1.0 / float64(n)
+	}
+	for a, _ := range posterior {
+		for b, _ := range posterior[a] {
+			assert.InDelta(t, posterior[a][b], freq[a][b], 2.0/math.Sqrt(float64(n)))
+		}
+	}
+}
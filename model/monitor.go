@@ -0,0 +1,156 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/golang/glog"
+)
+
+// the training metrics recorded after one sweep
+type Metrics struct {
+	Iteration     int       `json:"iter"`
+	LogLikelihood float64   `json:"log_likelihood"`
+	Perplexity    float64   `json:"perplexity"`
+	TokensPerSec  float64   `json:"tokens_per_sec"`
+	TopicChanges  uint64    `json:"topic_changes"`
+	Alphas        []float32 `json:"alpha"`
+	Beta          float32   `json:"beta"`
+}
+
+// Monitor records training metrics every Interval iterations to a
+// metrics file and decides whether training has converged
+type Monitor struct {
+	Interval  int     // iterations between records
+	Threshold float64 // relative likelihood improvement to go on, 0 disables early stopping
+
+	file   *os.File
+	csv    *csv.Writer
+	json   *json.Encoder
+	prev   float64   // likelihood of the last record
+	seen   bool      // whether there is a last record
+	tokens uint64    // tokens sampled since the last record
+	since  time.Time // end of the last record
+}
+
+// NewMonitor creates a monitor writing to fn, the metrics are written
+// as csv if fn ends with .csv and as json lines otherwise, nothing is
+// written if fn is empty
+func NewMonitor(fn string, interval int, threshold float64) (*Monitor, error) {
+	this := &Monitor{
+		Interval:  interval,
+		Threshold: threshold,
+		since:     time.Now(),
+	}
+	if fn == "" {
+		return this, nil
+	}
+	f, err := os.Create(fn)
+	if err != nil {
+		return nil, err
+	}
+	this.file = f
+	if strings.HasSuffix(fn, ".csv") {
+		this.csv = csv.NewWriter(f)
+		this.csv.Write([]string{"iter", "log_likelihood", "perplexity",
+			"tokens_per_sec", "topic_changes", "alpha", "beta"})
+	} else {
+		this.json = json.NewEncoder(f)
+	}
+	return this, nil
+}
+
+// record training metrics with monitor m
+func (this *LDA) SetMonitor(m *Monitor) {
+	this.monitor = m
+}
+
+// whether metrics should be recorded after iteration
+func (this *Monitor) due(iteration int) bool {
+	return this.Interval > 0 && iteration%this.Interval == 0
+}
+
+// write metrics to the metrics file
+func (this *Monitor) write(m *Metrics) error {
+	if this.json != nil {
+		return this.json.Encode(m)
+	}
+	if this.csv != nil {
+		alphas := make([]string, len(m.Alphas))
+		for k, a := range m.Alphas {
+			alphas[k] = strconv.FormatFloat(float64(a), 'g', -1, 32)
+		}
+		this.csv.Write([]string{
+			strconv.Itoa(m.Iteration),
+			strconv.FormatFloat(m.LogLikelihood, 'f', 6, 64),
+			strconv.FormatFloat(m.Perplexity, 'f', 6, 64),
+			strconv.FormatFloat(m.TokensPerSec, 'f', 2, 64),
+			strconv.FormatUint(m.TopicChanges, 10),
+			strings.Join(alphas, " "),
+			strconv.FormatFloat(float64(m.Beta), 'g', -1, 32),
+		})
+		this.csv.Flush()
+		return this.csv.Error()
+	}
+	return nil
+}
+
+// record the sweep of tokens tokens with changes topic changes after
+// iteration, the likelihood is only computed if a record is due,
+// returns true if the relative likelihood improvement since the last
+// record is below threshold
+func (this *Monitor) Record(iteration int, tokens, changes uint64,
+	likelihood func() float64, alphas []float32, beta float32) bool {
+	this.tokens += tokens
+	if !this.due(iteration) {
+		return false
+	}
+
+	m := &Metrics{
+		Iteration:     iteration,
+		LogLikelihood: likelihood(),
+		TokensPerSec:  float64(this.tokens) / time.Since(this.since).Seconds(),
+		TopicChanges:  changes,
+		Alphas:        alphas,
+		Beta:          beta,
+	}
+	if tokens > 0 {
+		m.Perplexity = math.Exp(-m.LogLikelihood / float64(tokens))
+	}
+	log.Infof("iter %5d, likelihood %f, perplexity %f, %.0f tokens/sec, %d topic changes",
+		m.Iteration, m.LogLikelihood, m.Perplexity, m.TokensPerSec, m.TopicChanges)
+	if err := this.write(m); err != nil {
+		log.Errorf("fail to write metrics of iter %d: %v", iteration, err)
+	}
+
+	converged := false
+	if this.seen && this.Threshold > 0 {
+		improvement := (m.LogLikelihood - this.prev) / math.Abs(this.prev)
+		if improvement < this.Threshold {
+			log.Infof("relative improvement %g below %g, stop at iter %d",
+				improvement, this.Threshold, iteration)
+			converged = true
+		}
+	}
+	this.prev = m.LogLikelihood
+	this.seen = true
+	this.tokens = 0
+	this.since = time.Now()
+	return converged
+}
+
+// flush and close the metrics file
+func (this *Monitor) Close() error {
+	if this.file == nil {
+		return nil
+	}
+	if this.csv != nil {
+		this.csv.Flush()
+	}
+	return this.file.Close()
+}
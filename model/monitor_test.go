@@ -0,0 +1,99 @@
+package model
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// record a fixed likelihood trace, returns the iteration stopping
+// training or 0 if it goes on
+func recordTrace(m *Monitor, trace []float64) int {
+	for iter, ll := range trace {
+		likelihood := func() float64 { return ll }
+		if m.Record(iter+1, 100, 10, likelihood, []float32{0.1, 0.2}, 0.01) {
+			return iter + 1
+		}
+	}
+	return 0
+}
+
+func TestMonitorEarlyStop(t *testing.T) {
+	trace := []float64{-1000, -900, -850, -849, -848.9, -848.8}
+
+	m, err := NewMonitor("", 1, 0.01)
+	assert.Nil(t, err)
+	// improvements are 0.1, 0.056, 0.0012
+	assert.Equal(t, 4, recordTrace(m, trace))
+
+	// the likelihood is compared between records only
+	m, err = NewMonitor("", 2, 0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, recordTrace(m, trace))
+
+	// early stopping is disabled by threshold 0
+	m, err = NewMonitor("", 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, recordTrace(m, trace))
+}
+
+func TestMonitorFile(t *testing.T) {
+	dir := t.TempDir()
+	trace := []float64{-1000, -900, -850, -849}
+
+	fn := filepath.Join(dir, "metrics.csv")
+	m, err := NewMonitor(fn, 2, 0)
+	assert.Nil(t, err)
+	recordTrace(m, trace)
+	assert.Nil(t, m.Close())
+	f, err := os.Open(fn)
+	assert.Nil(t, err)
+	rows, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, []string{"iter", "log_likelihood", "perplexity",
+		"tokens_per_sec", "topic_changes", "alpha", "beta"}, rows[0])
+	assert.Equal(t, "2", rows[1][0])
+	assert.Equal(t, "-900.000000", rows[1][1])
+	assert.Equal(t, "0.1 0.2", rows[1][5])
+	assert.Equal(t, "4", rows[2][0])
+
+	fn = filepath.Join(dir, "metrics.json")
+	m, err = NewMonitor(fn, 1, 0)
+	assert.Nil(t, err)
+	recordTrace(m, trace)
+	assert.Nil(t, m.Close())
+	f, err = os.Open(fn)
+	assert.Nil(t, err)
+	defer f.Close()
+	var records []Metrics
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Metrics
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	assert.Equal(t, 4, len(records))
+	assert.Equal(t, 3, records[2].Iteration)
+	assert.Equal(t, -850.0, records[2].LogLikelihood)
+	assert.Equal(t, uint64(10), records[2].TopicChanges)
+	assert.Equal(t, []float32{0.1, 0.2}, records[2].Alphas)
+}
+
+func TestMonitorSupport(t *testing.T) {
+	for _, ctor := range []ModelCtor{NewLDA, NewSparseLDA} {
+		_, ok := ctor(uint32(4), 0.1, 0.01).(MonitorSetter)
+		assert.True(t, ok)
+	}
+	for _, ctor := range []ModelCtor{NewAliasLDA, NewFTreeLDA, NewWarpLDA,
+		NewLightLDA, NewPYPLDA, NewHDP, NewAuthorTopic, NewADLDA} {
+		_, ok := ctor(uint32(4), 0.1, 0.01).(MonitorSetter)
+		assert.False(t, ok)
+	}
+}
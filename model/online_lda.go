@@ -0,0 +1,414 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("onlinelda", NewOnlineLDA)
+}
+
+// OnlineLDA is the online variational bayes algorithm of Hoffman, Blei
+// and Bach (2010). Documents are processed in minibatches, the local
+// variational parameters gamma of a minibatch are fitted with the
+// topics held fixed, and the topic-word parameter lambda moves toward
+// the estimate from the minibatch with the decaying learning rate
+// (Tau0 + t)^(-Kappa). Only lambda is kept between minibatches, so the
+// corpus can be streamed from disk by TrainStream.
+type OnlineLDA struct {
+	Alpha     float32 // document topic mixture hyperparameter
+	Eta       float32 // topic word mixture hyperparameter
+	TopicNum  uint32
+	Tau0      float64 // learning rate delay
+	Kappa     float64 // learning rate decay
+	BatchSize int     // number of documents of each minibatch
+	DocNum    uint32  // number of documents of the whole corpus
+	VocabSize uint32
+	Updates   int // number of minibatches processed
+
+	Data *corpus.Corpus // for convenience
+
+	Lambda *sstable.Float32Matrix // word-topic variational parameter
+	Gamma  *sstable.Float32Matrix // doc-topic variational parameter of Data
+
+	lambdaSum []float64 // summation of lambda of each topic
+	rng       *rand.Rand
+}
+
+// NewOnlineLDA creates an online variational bayes LDA instance, the
+// learning rate, minibatch size and corpus shape for streaming can be
+// changed by SetHyperParam
+func NewOnlineLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &OnlineLDA{
+		Alpha:     alpha,
+		Eta:       beta,
+		TopicNum:  topicNum,
+		Tau0:      1.0,
+		Kappa:     0.7,
+		BatchSize: 256,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// set tau0, kappa, batch_size, doc_num or vocab_size of the model
+func (this *OnlineLDA) SetHyperParam(name string, value float64) error {
+	switch name {
+	case "tau0":
+		if value < 0 {
+			return fmt.Errorf("tau0 should be non-negative: %f", value)
+		}
+		this.Tau0 = value
+	case "kappa":
+		if value <= 0.5 || value > 1 {
+			return fmt.Errorf("kappa should be in (0.5, 1]: %f", value)
+		}
+		this.Kappa = value
+	case "batch_size":
+		if value < 1 {
+			return fmt.Errorf("batch_size should be positive: %f", value)
+		}
+		this.BatchSize = int(value)
+	case "doc_num":
+		this.DocNum = uint32(value)
+	case "vocab_size":
+		this.VocabSize = uint32(value)
+	default:
+		return fmt.Errorf("unknown hyperparameter of onlinelda: %s", name)
+	}
+	return nil
+}
+
+// seed the random source of lambda and gamma initialization
+func (this *OnlineLDA) Seed(seed int64) {
+	this.rng = rand.New(rand.NewSource(seed))
+}
+
+// randomly init lambda if it is not initialized or loaded
+func (this *OnlineLDA) initLambda() {
+	if this.Lambda != nil {
+		return
+	}
+	this.Lambda = sstable.NewFloat32Matrix(this.VocabSize, this.TopicNum)
+	this.lambdaSum = make([]float64, this.TopicNum)
+	for w := uint32(0); w < this.VocabSize; w += 1 {
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			val := sampleGamma(this.rng, 100.0) / 100.0
+			this.Lambda.Set(w, k, float32(val))
+			this.lambdaSum[k] += val
+		}
+	}
+}
+
+// compute exp(E[log beta_kw]) of the words in docs
+func (this *OnlineLDA) expElogBeta(docs map[uint32][]*corpus.WordCount) map[uint32][]float64 {
+	digammaSum := make([]float64, this.TopicNum)
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		digammaSum[k] = digamma(this.lambdaSum[k])
+	}
+	result := make(map[uint32][]float64)
+	for _, wcs := range docs {
+		for _, wc := range wcs {
+			if _, ok := result[wc.WordId]; ok || wc.WordId >= this.VocabSize {
+				continue
+			}
+			row := make([]float64, this.TopicNum)
+			for k := uint32(0); k < this.TopicNum; k += 1 {
+				row[k] = math.Exp(digamma(float64(this.Lambda.Get(wc.WordId, k))) -
+					digammaSum[k])
+			}
+			result[wc.WordId] = row
+		}
+	}
+	return result
+}
+
+// fit gamma of one document with topics held fixed, the expected word
+// topic counts of the document are added to sstats if it is not nil
+func (this *OnlineLDA) inferDoc(wcs []*corpus.WordCount, expElogBeta map[uint32][]float64,
+	gamma []float64, sstats map[uint32][]float64, maxIter int) {
+	expElogTheta := make([]float64, this.TopicNum)
+	phiNorm := make([]float64, len(wcs))
+	last := make([]float64, this.TopicNum)
+
+	update := func() {
+		sum := 0.0
+		for _, g := range gamma {
+			sum += g
+		}
+		digammaSum := digamma(sum)
+		for k, g := range gamma {
+			expElogTheta[k] = math.Exp(digamma(g) - digammaSum)
+		}
+		for i, wc := range wcs {
+			phiNorm[i] = 1e-100
+			if beta, ok := expElogBeta[wc.WordId]; ok {
+				for k, t := range expElogTheta {
+					phiNorm[i] += t * beta[k]
+				}
+			}
+		}
+	}
+
+	for k, _ := range gamma {
+		gamma[k] = sampleGamma(this.rng, 100.0) / 100.0
+	}
+	update()
+	for iterIdx := 0; iterIdx < maxIter; iterIdx += 1 {
+		copy(last, gamma)
+		for k, _ := range gamma {
+			gamma[k] = 0.0
+		}
+		for i, wc := range wcs {
+			beta, ok := expElogBeta[wc.WordId]
+			if !ok {
+				continue
+			}
+			for k, _ := range gamma {
+				gamma[k] += float64(wc.Count) * beta[k] / phiNorm[i]
+			}
+		}
+		change := 0.0
+		for k, _ := range gamma {
+			gamma[k] = float64(this.Alpha) + expElogTheta[k]*gamma[k]
+			change += math.Abs(gamma[k] - last[k])
+		}
+		update()
+		if change/float64(this.TopicNum) < 1e-3 {
+			break
+		}
+	}
+
+	if sstats == nil {
+		return
+	}
+	for i, wc := range wcs {
+		row, ok := sstats[wc.WordId]
+		if !ok {
+			continue
+		}
+		for k, t := range expElogTheta {
+			row[k] += t * float64(wc.Count) / phiNorm[i]
+		}
+	}
+}
+
+// the learning rate of next minibatch
+func (this *OnlineLDA) learningRate() float64 {
+	return math.Pow(this.Tau0+float64(this.Updates), -this.Kappa)
+}
+
+// fit one minibatch and move lambda toward its estimate with
+// learning rate rho
+func (this *OnlineLDA) update(batch *corpus.Corpus, rho float64) {
+	expElogBeta := this.expElogBeta(batch.Docs)
+	sstats := make(map[uint32][]float64)
+	for w, _ := range expElogBeta {
+		sstats[w] = make([]float64, this.TopicNum)
+	}
+	gamma := make([]float64, this.TopicNum)
+	for _, doc := range batch.DocIds() {
+		this.inferDoc(batch.Docs[doc], expElogBeta, gamma, sstats, 100)
+	}
+
+	scale := float64(this.DocNum) / float64(len(batch.Docs))
+	if scale < 1 {
+		scale = 1
+	}
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		this.lambdaSum[k] = 0.0
+	}
+	for w := uint32(0); w < this.VocabSize; w += 1 {
+		row, ok := sstats[w]
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			estimate := float64(this.Eta)
+			if ok {
+				estimate += scale * row[k] * expElogBeta[w][k]
+			}
+			val := (1.0-rho)*float64(this.Lambda.Get(w, k)) + rho*estimate
+			this.Lambda.Set(w, k, float32(val))
+			this.lambdaSum[k] += val
+		}
+	}
+	this.Updates += 1
+}
+
+// fit gamma of all documents of Data with topics held fixed
+func (this *OnlineLDA) inferGamma(maxIter int) {
+	expElogBeta := this.expElogBeta(this.Data.Docs)
+	this.Gamma = sstable.NewFloat32Matrix(this.Data.DocNum, this.TopicNum)
+	gamma := make([]float64, this.TopicNum)
+	for _, doc := range this.Data.DocIds() {
+		this.inferDoc(this.Data.Docs[doc], expElogBeta, gamma, nil, maxIter)
+		for k, g := range gamma {
+			this.Gamma.Set(doc, uint32(k), float32(g))
+		}
+	}
+}
+
+// train on the in-memory corpus for iter passes of minibatches
+func (this *OnlineLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	this.Data = dat
+	this.DocNum = dat.DocNum
+	if this.Lambda == nil {
+		this.VocabSize = dat.VocabSize
+	} else if dat.VocabSize > this.VocabSize {
+		log.Warningf("words beyond vocabulary size %d are ignored", this.VocabSize)
+	}
+	this.initLambda()
+
+	docIds := dat.DocIds()
+	batch := &corpus.Corpus{}
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		log.Infof("iter %5d, minibatch updates %d", iterIdx, this.Updates)
+		for begin := 0; begin < len(docIds); begin += this.BatchSize {
+			end := begin + this.BatchSize
+			if end > len(docIds) {
+				end = len(docIds)
+			}
+			batch.Docs = make(map[uint32][]*corpus.WordCount)
+			for _, doc := range docIds[begin:end] {
+				batch.Docs[doc] = dat.Docs[doc]
+			}
+			this.update(batch, this.learningRate())
+		}
+	}
+
+	this.inferGamma(100)
+}
+
+// train on the minibatches of one pass of the iterator, the vocabulary
+// size and the number of documents of the whole stream should be set
+// by SetHyperParam before the first minibatch
+func (this *OnlineLDA) TrainStream(it corpus.DocumentIterator) error {
+	if this.Lambda == nil && this.VocabSize == 0 {
+		return fmt.Errorf("vocab_size should be set for streaming")
+	}
+	if this.DocNum == 0 {
+		return fmt.Errorf("doc_num should be set for streaming")
+	}
+	this.initLambda()
+
+	for {
+		batch, err := corpus.ReadBatch(it, this.BatchSize)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if batch.VocabSize > this.VocabSize {
+			log.Warningf("words beyond vocabulary size %d are ignored", this.VocabSize)
+		}
+		this.update(batch, this.learningRate())
+		if this.Updates%100 == 0 {
+			log.Infof("minibatch updates %d", this.Updates)
+		}
+	}
+}
+
+// infer topics on new documents, iter bounds the number of iterations
+// fitting gamma of each document
+func (this *OnlineLDA) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Lambda == nil {
+		log.Fatal("Lambda is not initialized, maybe model is not loaded")
+	}
+	this.Data = dat
+	this.inferGamma(iter)
+}
+
+// compute the expectation of word-topic mixture from lambda
+func (this *OnlineLDA) Phi() *sstable.Float32Matrix {
+	phi := sstable.NewFloat32Matrix(this.VocabSize, this.TopicNum)
+
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		for w := uint32(0); w < this.VocabSize; w += 1 {
+			phi.Set(w, k, float32(float64(this.Lambda.Get(w, k))/this.lambdaSum[k]))
+		}
+	}
+
+	return phi
+}
+
+// compute the expectation of document-topic mixture from gamma
+func (this *OnlineLDA) Theta() *sstable.Float32Matrix {
+	theta := sstable.NewFloat32Matrix(this.Data.DocNum, this.TopicNum)
+
+	for d := uint32(0); d < this.Data.DocNum; d += 1 {
+		sum := float32(0.0)
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			sum += this.Gamma.Get(d, k)
+		}
+		if sum == 0 {
+			continue
+		}
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			theta.Set(d, k, this.Gamma.Get(d, k)/sum)
+		}
+	}
+
+	return theta
+}
+
+// serialize word-topic distribution
+func (this *OnlineLDA) SavePhi(fn string) error {
+	phi := this.Phi()
+	if err := sstable.Float32Serialize(phi, fn); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize document-topic distribution, rows are written with the
+// document keys of the corpus
+func (this *OnlineLDA) SaveTheta(fn string) error {
+	theta := this.Theta()
+	if err := sstable.Float32SerializeRows(theta, this.Data.DocKeys(), fn); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serialize word-topic variational parameter lambda, a stale .hyper
+// of the table is removed
+func (this *OnlineLDA) SaveWordTopic(fn string) error {
+	if err := sstable.Float32Serialize(this.Lambda, fn); err != nil {
+		return err
+	}
+	return removeHyperParams(fn)
+}
+
+// deserialize word-topic variational parameter lambda
+func (this *OnlineLDA) LoadWordTopic(fn string) error {
+	v, err := sstable.Float32Deserialize(fn)
+	if err != nil {
+		return err
+	}
+	vocab, topicNum := v.Shape()
+	if topicNum != this.TopicNum {
+		return fmt.Errorf("model has %d topics, %d expected", topicNum, this.TopicNum)
+	}
+	this.Lambda = v
+	this.VocabSize = vocab
+	this.lambdaSum = make([]float64, this.TopicNum)
+	for w := uint32(0); w < vocab; w += 1 {
+		for k := uint32(0); k < topicNum; k += 1 {
+			this.lambdaSum[k] += float64(this.Lambda.Get(w, k))
+		}
+	}
+	return nil
+}
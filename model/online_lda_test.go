@@ -0,0 +1,79 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/corpus"
+)
+
+// summation of all the entries of lambda
+func lambdaTotal(m *OnlineLDA) float64 {
+	sum := 0.0
+	for w := uint32(0); w < m.VocabSize; w += 1 {
+		for k := uint32(0); k < m.TopicNum; k += 1 {
+			sum += float64(m.Lambda.Get(w, k))
+		}
+	}
+	return sum
+}
+
+func TestOnlineLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewOnlineLDA(uint32(4), float32(0.1), float32(0.01)).(*OnlineLDA)
+	m.Seed(1)
+	m.SetHyperParam("batch_size", 5)
+	m.Train(dat, 3)
+	assert.Equal(t, 12, m.Updates)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	m.Infer(dat, 5)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestOnlineLDAUpdate(t *testing.T) {
+	// the expected counts of every token sum to one over topics, so
+	// the total of the minibatch estimate is eta*V*K plus the tokens of
+	// the minibatch scaled to the corpus size
+	dat := newTestCorpus()
+	docLen, _ := tokenCounts(dat)
+	m := NewOnlineLDA(uint32(4), float32(0.1), float32(0.01)).(*OnlineLDA)
+	m.Seed(1)
+	m.SetHyperParam("batch_size", 5)
+	m.Train(dat, 0)
+
+	batch := &corpus.Corpus{}
+	for iterIdx := 0; iterIdx < 8; iterIdx += 1 {
+		batch.Docs = make(map[uint32][]*corpus.WordCount)
+		tokens := 0.0
+		for doc := uint32(iterIdx%4) * 5; doc < uint32(iterIdx%4+1)*5; doc += 1 {
+			batch.Docs[doc] = dat.Docs[doc]
+			tokens += float64(docLen[doc])
+		}
+		rho := m.learningRate()
+		estimate := float64(m.Eta)*float64(m.VocabSize)*float64(m.TopicNum) +
+			float64(dat.DocNum)/5.0*tokens
+		expected := (1.0-rho)*lambdaTotal(m) + rho*estimate
+		m.update(batch, rho)
+		assert.InDelta(t, expected, lambdaTotal(m), 1e-3*expected)
+	}
+}
+
+func TestOnlineLDAStream(t *testing.T) {
+	// one pass of the stream is the same as one pass of the corpus
+	dat := newTestCorpus()
+	m := NewOnlineLDA(uint32(4), float32(0.1), float32(0.01)).(*OnlineLDA)
+	m.Seed(1)
+	m.SetHyperParam("batch_size", 5)
+	m.Train(dat, 1)
+
+	s := NewOnlineLDA(uint32(4), float32(0.1), float32(0.01)).(*OnlineLDA)
+	s.Seed(1)
+	s.SetHyperParam("batch_size", 5)
+	s.SetHyperParam("doc_num", float64(dat.DocNum))
+	s.SetHyperParam("vocab_size", float64(dat.VocabSize))
+	assert.Nil(t, s.TrainStream(corpus.NewMemoryIterator(dat)))
+	assert.Equal(t, m.Updates, s.Updates)
+	assert.Equal(t, m.Lambda, s.Lambda)
+}
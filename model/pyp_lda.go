@@ -0,0 +1,469 @@
+package model
+
+import (
+	"fmt"
+	"math"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("pyp", NewPYPLDA)
+}
+
+// PYPLDA replaces the symmetric Dirichlet prior of topic-word mixtures
+// by a Pitman-Yor process PYP(discount, concentration, H) with uniform
+// base distribution H over vocabulary, whose power-law behavior fits
+// word frequencies better. The restaurant of topic k is collapsed into
+// word counts n_wk and table counts t_wk, and each token carries a
+// table indicator telling whether it opened a table. Topics and
+// indicators are sampled jointly following Chen, Du and Buntine (2011)
+// "Sampling table configurations for the hierarchical Poisson-Dirichlet
+// process". Beta is not used by this model.
+type PYPLDA struct {
+	*ldaBase
+	Discount      float64
+	Concentration float64
+	MaxTables     uint32 // max number of tables of one word in one topic
+	MaxCustomers  uint32 // max number of customers of exact stirling numbers
+
+	Tt  *sstable.Uint32Matrix    // word-topic table count table
+	Tts *sstable.Uint32Matrix    // topic table count table
+	Dwu map[sstable.DocWord]bool // doc-word table indicator map
+
+	stirling *stirlingCache
+}
+
+// NewPYPLDA creates a Pitman-Yor topic model instance, the discount
+// and concentration can be changed by SetHyperParam
+func NewPYPLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &PYPLDA{
+		ldaBase:       newLDABase(topicNum, alpha, beta),
+		Discount:      0.5,
+		Concentration: 10.0,
+		MaxTables:     uint32(1000),
+		MaxCustomers:  uint32(2000),
+	}
+}
+
+// set discount, concentration, max_tables or max_customers of the
+// model, the stirling numbers of up to max_customers customers are
+// cached and the ones of more customers are approximated. The
+// constraints between hyperparameters are checked before training, so
+// they can be set in any order
+func (this *PYPLDA) SetHyperParam(name string, value float64) error {
+	switch name {
+	case "discount":
+		if value < 0 || value >= 1 {
+			return fmt.Errorf("discount should be in [0, 1): %f", value)
+		}
+		this.Discount = value
+	case "concentration":
+		this.Concentration = value
+	case "max_tables":
+		if value < 1 || value > math.MaxUint32 {
+			return fmt.Errorf("max_tables should be in [1, %d]: %f", uint32(math.MaxUint32), value)
+		}
+		this.MaxTables = uint32(value)
+	case "max_customers":
+		if value < 1 || value > math.MaxUint32 {
+			return fmt.Errorf("max_customers should be in [1, %d]: %f", uint32(math.MaxUint32), value)
+		}
+		this.MaxCustomers = uint32(value)
+	default:
+		return fmt.Errorf("unknown hyperparameter of pyp: %s", name)
+	}
+	return nil
+}
+
+// check the constraints between hyperparameters
+func (this *PYPLDA) checkHyperParams() error {
+	if this.Concentration <= -this.Discount {
+		return fmt.Errorf("concentration should be larger than -discount")
+	}
+	if this.MaxCustomers < this.MaxTables {
+		return fmt.Errorf("max_customers should not be less than max_tables")
+	}
+	return nil
+}
+
+// stirlingCache keeps the generalized stirling numbers S^n_{m,a} in
+// log space, rows are computed lazily using the recurrence
+// S^{n+1}_m = S^n_{m-1} + (n - m*a) S^n_m, at most maxM+1 columns are
+// kept in each row and at most maxN+1 rows are kept
+type stirlingCache struct {
+	a    float64
+	maxM uint32
+	maxN uint32
+	rows [][]float64
+}
+
+func newStirlingCache(a float64, maxM, maxN uint32) *stirlingCache {
+	return &stirlingCache{
+		a:    a,
+		maxM: maxM,
+		maxN: maxN,
+		rows: [][]float64{{0.0}}, // S^0_0 = 1
+	}
+}
+
+// add two numbers in log space
+func logAdd(x, y float64) float64 {
+	if math.IsInf(x, -1) {
+		return y
+	}
+	if math.IsInf(y, -1) {
+		return x
+	}
+	if x < y {
+		x, y = y, x
+	}
+	return x + math.Log1p(math.Exp(y-x))
+}
+
+// get row n of the cache, rows up to n are computed if needed
+func (this *stirlingCache) row(n uint32) []float64 {
+	for uint32(len(this.rows)) <= n {
+		prev := this.rows[len(this.rows)-1]
+		width := uint32(len(this.rows)) + uint32(1)
+		if width > this.maxM+uint32(1) {
+			width = this.maxM + uint32(1)
+		}
+		row := make([]float64, width)
+		row[0] = math.Inf(-1)
+		pn := float64(len(this.rows) - 1)
+		for c := uint32(1); c < width; c += 1 {
+			val := math.Inf(-1)
+			if c-1 < uint32(len(prev)) {
+				val = prev[c-1]
+			}
+			if c < uint32(len(prev)) && !math.IsInf(prev[c], -1) {
+				val = logAdd(val, prev[c]+math.Log(pn-float64(c)*this.a))
+			}
+			row[c] = val
+		}
+		this.rows = append(this.rows, row)
+	}
+	return this.rows[n]
+}
+
+// get log S^n_{m,a}, it is -Inf if the number is zero or m exceeds
+// maxM, n should not exceed maxN
+func (this *stirlingCache) LogS(n, m uint32) float64 {
+	if m > n || m > this.maxM || (m == 0 && n > 0) {
+		return math.Inf(-1)
+	}
+	return this.row(n)[m]
+}
+
+// get the ratios S^{n+1}_m / S^n_m and S^{n+1}_{m+1} / S^n_m, m should
+// be positive in the first ratio which is zero otherwise. The ratios
+// follow from the recurrence and row n alone, for n beyond maxN the
+// ratios of adjacent numbers in row n are approximated by the ones of
+// row maxN, so the memory is bounded however many customers a
+// restaurant has
+func (this *stirlingCache) Ratios(n, m uint32) (float64, float64) {
+	var row []float64
+	if n > this.maxN {
+		row = this.row(this.maxN)
+	} else {
+		row = this.row(n)
+	}
+	// log S^n_c - log S^n_m
+	logRatio := func(c uint32) float64 {
+		if c >= uint32(len(row)) || m >= uint32(len(row)) ||
+			math.IsInf(row[c], -1) {
+			return math.Inf(-1)
+		}
+		return row[c] - row[m]
+	}
+
+	same := 0.0
+	if m > 0 {
+		same = float64(n) - float64(m)*this.a + math.Exp(logRatio(m-1))
+	}
+	next := 0.0
+	if m < this.maxM {
+		next = 1.0 + (float64(n)-float64(m+1)*this.a)*math.Exp(logRatio(m+1))
+	}
+	return same, next
+}
+
+// randomly assign topics to words, the first customer of each word in
+// a topic opens the only table
+func (this *PYPLDA) Init() {
+	dw := sstable.DocWord{}
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		for i, w := range corpus.ExpandWords(wcs) {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			dw.DocId = doc
+			dw.WordIdx = uint32(i)
+
+			this.Dwt[dw] = k
+			this.Dwu[dw] = this.Wt.Get(w, k) == 0
+			if this.Dwu[dw] {
+				this.Tt.Incr(w, k, uint32(1))
+				this.Tts.Incr(k, uint32(0), uint32(1))
+			}
+			this.Wt.Incr(w, k, uint32(1))
+			this.Dt.Incr(doc, k, uint32(1))
+			this.Wts.Incr(k, uint32(0), uint32(1))
+		}
+	}
+}
+
+func (this *PYPLDA) ResampleTopics(iter int) {
+	dw := sstable.DocWord{}
+	// weights of (topic, indicator) pairs, the first half is for joining
+	// an existing table and the second half is for opening a new one
+	weights := make([]float64, 2*this.TopicNum)
+	cumsum := make([]float64, 2*this.TopicNum)
+	base := 1.0 / float64(this.Data.VocabSize)
+	a, b := this.Discount, this.Concentration
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			for i, w := range corpus.ExpandWords(wcs) {
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+				u := this.Dwu[dw]
+
+				// the only table of other customers can not be removed
+				if u && this.Tt.Get(w, k) == 1 && this.Wt.Get(w, k) > 1 {
+					continue
+				}
+
+				// decrease corresponding sufficient statistics
+				this.Wt.Decr(w, k, uint32(1))
+				this.Dt.Decr(doc, k, uint32(1))
+				this.Wts.Decr(k, uint32(0), uint32(1))
+				if u {
+					this.Tt.Decr(w, k, uint32(1))
+					this.Tts.Decr(k, uint32(0), uint32(1))
+				}
+
+				// resample the topic and table indicator
+				for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+					n := this.Wt.Get(w, kidx)
+					t := this.Tt.Get(w, kidx)
+					docPart := float64(this.Alpha) + float64(this.Dt.Get(doc, kidx))
+					denom := b + float64(this.Wts.Get(kidx, uint32(0)))
+					same, next := this.stirling.Ratios(n, t)
+
+					// join one of the existing tables
+					weights[kidx] = 0.0
+					if t > 0 {
+						weights[kidx] = docPart / denom * same *
+							float64(n+1-t) / float64(n+1)
+					}
+					// open a new table
+					weights[this.TopicNum+kidx] = docPart *
+						(b + a*float64(this.Tts.Get(kidx, uint32(0)))) / denom *
+						next * float64(t+1) / float64(n+1) * base
+				}
+				total := 0.0
+				for idx, weight := range weights {
+					total += weight
+					cumsum[idx] = total
+				}
+				r := this.rng.Float64() * total
+				idx := uint32(0)
+				for ; idx < 2*this.TopicNum-1; idx += 1 {
+					if r < cumsum[idx] {
+						break
+					}
+				}
+				k = idx % this.TopicNum
+				u = idx >= this.TopicNum
+
+				// increase corresponding sufficient statistics
+				this.Wt.Incr(w, k, uint32(1))
+				this.Dt.Incr(doc, k, uint32(1))
+				this.Wts.Incr(k, uint32(0), uint32(1))
+				if u {
+					this.Tt.Incr(w, k, uint32(1))
+					this.Tts.Incr(k, uint32(0), uint32(1))
+				}
+				this.Dwt[dw] = k
+				this.Dwu[dw] = u
+			}
+		}
+	}
+}
+
+func (this *PYPLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if err := this.checkHyperParams(); err != nil {
+		log.Fatal(err)
+	}
+	// create sstables
+	this.Wt = sstable.NewUint32Matrix(dat.VocabSize, this.TopicNum)
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	this.Tt = sstable.NewUint32Matrix(dat.VocabSize, this.TopicNum)
+	this.Tts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Dwu = make(map[sstable.DocWord]bool)
+	this.Data = dat
+	this.stirling = newStirlingCache(this.Discount, this.MaxTables, this.MaxCustomers)
+
+	// randomly init sstables
+	this.Init()
+
+	this.ResampleTopics(iter)
+}
+
+// infer topics on new documents, the topic-word mixtures of loaded
+// model are held fixed
+func (this *PYPLDA) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.Tt == nil {
+		log.Fatal("Wt or Tt is not initialized, maybe model is not loaded")
+	}
+	if err := this.checkHyperParams(); err != nil {
+		log.Fatal(err)
+	}
+	phi := this.Phi()
+	vocabSize, _ := phi.Shape()
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// probability of words not seen in training
+	unseen := make([]float32, this.TopicNum)
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		unseen[k] = float32((this.Concentration +
+			this.Discount*float64(this.Tts.Get(k, uint32(0)))) /
+			(this.Concentration + float64(this.Wts.Get(k, uint32(0)))) /
+			float64(vocabSize))
+	}
+	wordPart := func(w, k uint32) float32 {
+		if w >= vocabSize {
+			return unseen[k]
+		}
+		return phi.Get(w, k)
+	}
+
+	dw := sstable.DocWord{}
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		for i, _ := range corpus.ExpandWords(wcs) {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			this.Dt.Incr(doc, k, uint32(1))
+			dw.DocId = doc
+			dw.WordIdx = uint32(i)
+			this.Dwt[dw] = k
+		}
+	}
+
+	cumsum := make([]float32, this.TopicNum)
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			for i, w := range corpus.ExpandWords(wcs) {
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+				this.Dt.Decr(doc, k, uint32(1))
+
+				total := float32(0.0)
+				for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+					total += (this.Alpha + float32(this.Dt.Get(doc, kidx))) *
+						wordPart(w, kidx)
+					cumsum[kidx] = total
+				}
+				r := this.rng.Float32() * total
+				for k = 0; k < this.TopicNum-1; k += 1 {
+					if r < cumsum[k] {
+						break
+					}
+				}
+
+				this.Dt.Incr(doc, k, uint32(1))
+				this.Dwt[dw] = k
+			}
+		}
+	}
+}
+
+// compute the posterior predictive word-topic mixture of the PYP
+// (n_wk - a*t_wk + (b + a*T_k)*H(w)) / (b + N_k)
+func (this *PYPLDA) Phi() *sstable.Float32Matrix {
+	vocabSize, _ := this.Wt.Shape()
+	phi := sstable.NewFloat32Matrix(vocabSize, this.TopicNum)
+	a, b := this.Discount, this.Concentration
+	base := 1.0 / float64(vocabSize)
+
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		denom := b + float64(this.Wts.Get(k, uint32(0)))
+		newTable := (b + a*float64(this.Tts.Get(k, uint32(0)))) * base
+		for v := uint32(0); v < vocabSize; v += 1 {
+			result := (float64(this.Wt.Get(v, k)) -
+				a*float64(this.Tt.Get(v, k)) + newTable) / denom
+			phi.Set(v, k, float32(result))
+		}
+	}
+
+	return phi
+}
+
+// compute the joint likelihood of corpus
+func (this *PYPLDA) Likelihood() float64 {
+	return this.likelihood(this.Phi(), this.Theta())
+}
+
+// serialize the posterior predictive word-topic distribution
+func (this *PYPLDA) SavePhi(fn string) error {
+	return sstable.Float32Serialize(this.Phi(), fn)
+}
+
+// serialize word-topic matrix, the table counts are saved in
+// another file with suffix .table and the hyperparameters in .hyper
+func (this *PYPLDA) SaveWordTopic(fn string) error {
+	if err := sstable.Uint32Serialize(this.Wt, fn); err != nil {
+		return err
+	}
+	if err := sstable.Uint32Serialize(this.Tt, fn+".table"); err != nil {
+		return err
+	}
+	if err := this.saveHyperParams(fn + ".hyper"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deserialize word-topic matrix and table counts
+func (this *PYPLDA) LoadWordTopic(fn string) error {
+	if err := this.ldaBase.LoadWordTopic(fn); err != nil {
+		return err
+	}
+	v, err := sstable.Uint32Deserialize(fn + ".table")
+	if err != nil {
+		return err
+	}
+	this.Tt = v
+	// init topic table count table
+	this.Tts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	vocab, topicNum := this.Tt.Shape()
+	for r := uint32(0); r < vocab; r += 1 {
+		for t := uint32(0); t < topicNum; t += 1 {
+			this.Tts.Incr(t, uint32(0), this.Tt.Get(r, t))
+		}
+	}
+	return nil
+}
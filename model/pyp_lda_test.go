@@ -0,0 +1,74 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStirlingCache(t *testing.T) {
+	c := newStirlingCache(0.5, uint32(10), uint32(20))
+
+	// S^3_{1,a} = (1-a)(2-a), S^3_{2,a} = 3(1-a), S^3_3 = 1
+	assert.InDelta(t, math.Log(0.75), c.LogS(3, 1), 1e-9)
+	assert.InDelta(t, math.Log(1.5), c.LogS(3, 2), 1e-9)
+	assert.InDelta(t, 0.0, c.LogS(3, 3), 1e-9)
+	assert.True(t, math.IsInf(c.LogS(3, 4), -1))
+
+	for n := uint32(1); n < 20; n += 1 {
+		for m := uint32(1); m <= n && m < 10; m += 1 {
+			same, next := c.Ratios(n, m)
+			assert.InDelta(t, c.LogS(n+1, m)-c.LogS(n, m), math.Log(same), 1e-9)
+			assert.InDelta(t, c.LogS(n+1, m+1)-c.LogS(n, m), math.Log(next), 1e-9)
+		}
+	}
+
+	// no table can be opened beyond maxM
+	_, next := c.Ratios(15, 10)
+	assert.Equal(t, 0.0, next)
+
+	// rows beyond maxN are never computed
+	same, next := c.Ratios(1000000, 5)
+	assert.True(t, same > 0)
+	assert.True(t, next > 0)
+	assert.Equal(t, 21, len(c.rows))
+}
+
+func TestPYPLDATrain(t *testing.T) {
+	m := NewPYPLDA(uint32(4), float32(0.1), float32(0.01)).(*PYPLDA)
+	m.Seed(1)
+	m.Train(newTestCorpus(), 10)
+
+	assertCounts(t, m.Data, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	for w := uint32(0); w < m.Data.VocabSize; w += 1 {
+		for k := uint32(0); k < m.TopicNum; k += 1 {
+			// every word with customers in a topic has 1 to n tables
+			n, tables := m.Wt.Get(w, k), m.Tt.Get(w, k)
+			assert.True(t, tables <= n)
+			assert.Equal(t, n > 0, tables > 0)
+		}
+	}
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestPYPLDAHyperParams(t *testing.T) {
+	// the constraints between hyperparameters do not depend on the
+	// order they are set in
+	m := NewPYPLDA(uint32(4), float32(0.1), float32(0.01)).(*PYPLDA)
+	assert.Nil(t, SetHyperParams(m, "concentration=-0.3,discount=0.5"))
+	assert.Nil(t, m.checkHyperParams())
+	assert.Nil(t, SetHyperParams(m, "max_tables=5000,max_customers=6000"))
+	assert.Nil(t, m.checkHyperParams())
+
+	assert.Nil(t, SetHyperParams(m, "concentration=-0.6"))
+	assert.NotNil(t, m.checkHyperParams())
+	assert.Nil(t, SetHyperParams(m, "concentration=1,max_customers=100"))
+	assert.NotNil(t, m.checkHyperParams())
+
+	assert.NotNil(t, m.SetHyperParam("max_tables", 5e9))
+	assert.NotNil(t, m.SetHyperParam("max_customers", 5e9))
+	assert.NotNil(t, m.SetHyperParam("discount", 1.0))
+	assert.Equal(t, uint32(100), m.MaxCustomers)
+	assert.Equal(t, 0.5, m.Discount)
+}
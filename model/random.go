@@ -0,0 +1,91 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+)
+
+// draw a sample from Gamma(shape, 1) using the method of Marsaglia
+// and Tsang, shape less than one is boosted by a uniform power
+func sampleGamma(r *rand.Rand, shape float64) float64 {
+	if shape <= 0 {
+		return 0.0
+	}
+	if shape < 1 {
+		return sampleGamma(r, shape+1.0) * math.Pow(r.Float64(), 1.0/shape)
+	}
+	d := shape - 1.0/3.0
+	c := 1.0 / math.Sqrt(9.0*d)
+	for {
+		var x, v float64
+		for v <= 0 {
+			x = r.NormFloat64()
+			v = 1.0 + c*x
+		}
+		v = v * v * v
+		u := r.Float64()
+		if u < 1.0-0.0331*x*x*x*x {
+			return d * v
+		}
+		if math.Log(u) < 0.5*x*x+d*(1.0-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
+
+// draw a sample from Beta(a, b)
+func sampleBeta(r *rand.Rand, a, b float64) float64 {
+	x := sampleGamma(r, a)
+	y := sampleGamma(r, b)
+	if x+y <= 0 {
+		return 0.5
+	}
+	return x / (x + y)
+}
+
+// draw a sample from Dirichlet(params) into out
+func sampleDirichlet(r *rand.Rand, params []float64, out []float64) {
+	sum := 0.0
+	for i, p := range params {
+		out[i] = sampleGamma(r, p)
+		sum += out[i]
+	}
+	if sum <= 0 {
+		for i, _ := range out {
+			out[i] = 1.0 / float64(len(out))
+		}
+		return
+	}
+	for i, _ := range out {
+		out[i] /= sum
+	}
+}
+
+// rngSource is the splitmix64 generator of Steele, Lea and Flood
+// (2014), unlike the source of math/rand its whole state is one
+// integer, so samplers using it can save and restore their random
+// state in checkpoints
+type rngSource struct {
+	state uint64
+}
+
+// create a splitmix64 source with seed
+func newRNGSource(seed int64) *rngSource {
+	return &rngSource{state: uint64(seed)}
+}
+
+func (this *rngSource) Seed(seed int64) {
+	this.state = uint64(seed)
+}
+
+func (this *rngSource) Uint64() uint64 {
+	this.state += 0x9e3779b97f4a7c15
+	z := this.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (this *rngSource) Int63() int64 {
+	return int64(this.Uint64() >> 1)
+}
@@ -1,8 +1,7 @@
 package model
 
 import (
-	"math"
-	"math/rand"
+	"fmt"
 
 	log "github.com/golang/glog"
 
@@ -23,21 +22,16 @@ type SparseLDA struct {
 // and memory efficient gibbs sampler
 func NewSparseLDA(topicNum uint32, alpha float32, beta float32) Model {
 	return &SparseLDA{
-		LDA: NewLDA(topicNum, alpha, beta).(*LDA),
+		LDA: &LDA{
+			ldaBase: newLDABase(topicNum, alpha, beta),
+		},
 		Wtm: sstable.NewSortedMap(topicNum),
 	}
 }
 
 func (this *SparseLDA) ResampleTopics(iter int) {
 	dw := sstable.DocWord{}
-
-	// compute smoothing bucket
-	smoothingBucket := float32(0.0)
-	for k := uint32(0); k < this.TopicNum; k += 1 {
-		smoothingBucket += (this.Alpha * this.Beta) /
-			(this.Beta*float32(this.Data.VocabSize) +
-				float32(this.Wts.Get(k, uint32(0))))
-	}
+	betaSum := this.Beta * float32(this.Data.VocabSize)
 
 	// word-topic bucket cache
 	wtbCache := make([]float32, this.TopicNum)
@@ -45,19 +39,31 @@ func (this *SparseLDA) ResampleTopics(iter int) {
 		if iterIdx%10 == 0 && iterIdx > 0 {
 			log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
 		}
+		if this.training && this.OptimizeInterval > 0 &&
+			this.Iteration > 0 && this.Iteration%this.OptimizeInterval == 0 {
+			this.OptimizeHyperParams()
+			betaSum = this.Beta * float32(this.Data.VocabSize)
+		}
+
+		// compute smoothing bucket
+		smoothingBucket := float32(0.0)
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			smoothingBucket += (this.Alphas[k] * this.Beta) /
+				(betaSum + float32(this.Wts.Get(k, uint32(0))))
+		}
 
 		// fast sparse gibbs sampling
-		for doc, wcs := range this.Data.Docs {
+		tokens, changes := uint64(0), uint64(0)
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
 			// document-topic bucket
 			docTopicBucket := float32(0.0)
 
 			for k := uint32(0); k < this.TopicNum; k += 1 {
 				docTopicBucket += (this.Beta * float32(this.Dt.Get(doc, k))) /
-					(this.Beta*float32(this.Data.VocabSize) +
-						float32(this.Wts.Get(k, uint32(0))))
-				wtbCache[k] = (this.Alpha + float32(this.Dt.Get(doc, k))) /
-					(this.Beta*float32(this.Data.VocabSize) +
-						float32(this.Wts.Get(k, uint32(0))))
+					(betaSum + float32(this.Wts.Get(k, uint32(0))))
+				wtbCache[k] = (this.Alphas[k] + float32(this.Dt.Get(doc, k))) /
+					(betaSum + float32(this.Wts.Get(k, uint32(0))))
 			}
 
 			for i, w := range corpus.ExpandWords(wcs) {
@@ -65,11 +71,11 @@ func (this *SparseLDA) ResampleTopics(iter int) {
 				dw.DocId = doc
 				dw.WordIdx = uint32(i)
 				k := this.Dwt[dw]
+				oldK := k
 
 				// subtract old value from buckets
-				denom := (this.Beta*float32(this.Data.VocabSize) +
-					float32(this.Wts.Get(k, uint32(0))))
-				smoothingBucket -= (this.Alpha * this.Beta) / denom
+				denom := betaSum + float32(this.Wts.Get(k, uint32(0)))
+				smoothingBucket -= (this.Alphas[k] * this.Beta) / denom
 				docTopicBucket -= (this.Beta * float32(this.Dt.Get(doc, k))) / denom
 
 				// decrease corresponding sufficient statistics
@@ -78,11 +84,10 @@ func (this *SparseLDA) ResampleTopics(iter int) {
 				this.Wts.Decr(k, uint32(0), uint32(1))
 
 				// update bucket values
-				denom = (this.Beta*float32(this.Data.VocabSize) +
-					float32(this.Wts.Get(k, uint32(0))))
-				smoothingBucket += (this.Alpha * this.Beta) / denom
+				denom = betaSum + float32(this.Wts.Get(k, uint32(0)))
+				smoothingBucket += (this.Alphas[k] * this.Beta) / denom
 				docTopicBucket += (this.Beta * float32(this.Dt.Get(doc, k))) / denom
-				wtbCache[k] = (this.Alpha + float32(this.Dt.Get(doc, k))) / denom
+				wtbCache[k] = (this.Alphas[k] + float32(this.Dt.Get(doc, k))) / denom
 
 				// compute word-topic bucket sum
 				wtbSum := float32(0.0)
@@ -95,41 +100,47 @@ func (this *SparseLDA) ResampleTopics(iter int) {
 
 				// resample topic assignment
 				var cumsum float32
-				u := rand.Float32() * (wtbSum + dtbSum + sbSum)
+				u := this.rng.Float32() * (wtbSum + dtbSum + sbSum)
 				if u < wtbSum { // topic-word bucket
 					cumsum = 0.0
 					for tcIdx, _ := range this.Wtm.Data[w] {
 						tid, count := this.Wtm.Get(w, tcIdx)
 						cumsum += wtbCache[tid] * float32(count)
+						k = tid
 						if cumsum >= u {
-							k = tid
 							break
 						}
 					}
-				} else if u < (wtbSum+dtbSum) && u >= wtbSum { // doc-topic bucket
+				} else if u < (wtbSum + dtbSum) { // doc-topic bucket
 					cumsum = 0.0
 					u = u - wtbSum
 					for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
-						cumsum += (this.Beta * float32(this.Dt.Get(doc, k))) / denom
+						cnt := this.Dt.Get(doc, kidx)
+						if cnt == 0 {
+							continue
+						}
+						cumsum += (this.Beta * float32(cnt)) /
+							(betaSum + float32(this.Wts.Get(kidx, uint32(0))))
+						k = kidx
 						if cumsum >= u {
-							k = kidx
 							break
 						}
 					}
 				} else { // smoothing bucket
 					cumsum = 0.0
+					u = u - wtbSum - dtbSum
 					for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
-						cumsum += (this.Alpha * this.Beta) / denom
+						cumsum += (this.Alphas[kidx] * this.Beta) /
+							(betaSum + float32(this.Wts.Get(kidx, uint32(0))))
+						k = kidx
 						if cumsum >= u {
-							k = kidx
 							break
 						}
 					}
 				}
 
-				denom = (this.Beta*float32(this.Data.VocabSize) +
-					float32(this.Wts.Get(k, uint32(0))))
-				smoothingBucket -= (this.Alpha * this.Beta) / denom
+				denom = betaSum + float32(this.Wts.Get(k, uint32(0)))
+				smoothingBucket -= (this.Alphas[k] * this.Beta) / denom
 				docTopicBucket -= (this.Beta * float32(this.Dt.Get(doc, k))) / denom
 
 				// increase corresponding sufficient statistics
@@ -139,14 +150,83 @@ func (this *SparseLDA) ResampleTopics(iter int) {
 				this.Dwt[dw] = k
 
 				// update bucket values
-				denom = (this.Beta*float32(this.Data.VocabSize) +
-					float32(this.Wts.Get(k, uint32(0))))
-				smoothingBucket += (this.Alpha * this.Beta) / denom
+				denom = betaSum + float32(this.Wts.Get(k, uint32(0)))
+				smoothingBucket += (this.Alphas[k] * this.Beta) / denom
 				docTopicBucket += (this.Beta * float32(this.Dt.Get(doc, k))) / denom
-				wtbCache[k] = (this.Alpha + float32(this.Dt.Get(doc, k))) / denom
+				wtbCache[k] = (this.Alphas[k] + float32(this.Dt.Get(doc, k))) / denom
+
+				tokens += 1
+				if k != oldK {
+					changes += 1
+				}
+			}
+		}
+
+		if this.training {
+			this.Iteration += 1
+			// the checkpoint holds the sample of the same iteration
+			if this.sampleDue() {
+				this.takeSample(this.statePhi(), this.stateTheta())
+			}
+			if this.checkpointDue() {
+				this.checkpoint(this.wordTopic())
+			}
+			if this.monitor != nil && this.monitor.Record(this.Iteration,
+				tokens, changes, this.Likelihood, this.Alphas, this.Beta) {
+				break
+			}
+		}
+	}
+}
+
+// convert the word-topic map to dense count table
+func (this *SparseLDA) wordTopic() *sstable.Uint32Matrix {
+	wt := sstable.NewUint32Matrix(this.Data.VocabSize, this.TopicNum)
+	for w, _ := range this.Wtm.Data {
+		for idx, _ := range this.Wtm.Data[w] {
+			if topicId, count := this.Wtm.Get(w, idx); count > 0 {
+				wt.Set(w, topicId, count)
+			}
+		}
+	}
+	return wt
+}
+
+// continue training from checkpoint fn until iter iterations are
+// finished in total
+func (this *SparseLDA) Resume(dat *corpus.Corpus, fn string, iter int) error {
+	if err := this.loadCheckpoint(dat, fn); err != nil {
+		return err
+	}
+	this.Wtm = sstable.NewSortedMap(this.TopicNum)
+	row, col := this.Wt.Shape()
+	for r := uint32(0); r < row; r += 1 {
+		for c := uint32(0); c < col; c += 1 {
+			if cnt := this.Wt.Get(r, c); cnt > 0 {
+				this.Wtm.Incr(r, c, cnt)
+			}
+		}
+	}
+	this.Wt = nil
+
+	this.training = true
+	this.ResampleTopics(iter - this.Iteration)
+	this.training = false
+	this.checkSamples()
+	return nil
+}
+
+// learn hyperparameters from the current counts
+func (this *SparseLDA) OptimizeHyperParams() {
+	var countHist []uint32
+	for w, _ := range this.Wtm.Data {
+		for idx, _ := range this.Wtm.Data[w] {
+			if _, cnt := this.Wtm.Get(w, idx); cnt > 0 {
+				countHist = histogramAdd(countHist, cnt)
 			}
 		}
 	}
+	this.updateHyperParams(countHist)
 }
 
 func (this *SparseLDA) Train(dat *corpus.Corpus, iter int) {
@@ -173,20 +253,152 @@ func (this *SparseLDA) Train(dat *corpus.Corpus, iter int) {
 	}
 	this.Wt = nil
 
+	this.Iteration = 0
+	this.phiSum, this.thetaSum = nil, nil
+	this.training = true
 	this.ResampleTopics(iter)
+	this.training = false
+	this.checkSamples()
 }
 
-// infer topics on new documents
+// infer topics on new documents, the word-topic counts loaded from
+// model file are held fixed and only the document-topic counts are
+// resampled, so the normalizer of each topic never changes and the
+// smoothing bucket only needs to be computed once
 func (this *SparseLDA) Infer(dat *corpus.Corpus, iter int) {
-	// TODO
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wts == nil {
+		log.Fatal("Wtm or Wts is not initialized, maybe model is not loaded")
+	}
+	this.thetaSum = nil
+	this.Dt = sstable.NewUint32Matrix(dat.DocNum, this.TopicNum)
+	this.Dwt = make(map[sstable.DocWord]uint32)
+	this.Data = dat
+
+	// the vocabulary of training corpus, words not seen in training
+	// have no word-topic counts and fall into the other two buckets
+	vocabSize := this.Wtm.MaxWordId + uint32(1)
+
+	// cache topic normalizers and compute smoothing bucket
+	denom := make([]float32, this.TopicNum)
+	smoothingBucket := float32(0.0)
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		denom[k] = this.Beta*float32(vocabSize) +
+			float32(this.Wts.Get(k, uint32(0)))
+		smoothingBucket += (this.Alphas[k] * this.Beta) / denom[k]
+	}
+
+	// randomly assign topic to word, only doc-topic table is touched
+	dw := sstable.DocWord{}
+	for _, doc := range this.Data.DocIds() {
+		wcs := this.Data.Docs[doc]
+		for i, _ := range corpus.ExpandWords(wcs) {
+			k := uint32(this.rng.Int31n(int32(this.TopicNum)))
+			this.Dt.Incr(doc, k, uint32(1))
+			dw.DocId = doc
+			dw.WordIdx = uint32(i)
+			this.Dwt[dw] = k
+		}
+	}
+
+	// word-topic bucket cache
+	wtbCache := make([]float32, this.TopicNum)
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		for _, doc := range this.Data.DocIds() {
+			wcs := this.Data.Docs[doc]
+			// document-topic bucket
+			docTopicBucket := float32(0.0)
+			for k := uint32(0); k < this.TopicNum; k += 1 {
+				docTopicBucket += (this.Beta * float32(this.Dt.Get(doc, k))) / denom[k]
+				wtbCache[k] = (this.Alphas[k] + float32(this.Dt.Get(doc, k))) / denom[k]
+			}
+
+			for i, w := range corpus.ExpandWords(wcs) {
+				// get the current topic of word w
+				dw.DocId = doc
+				dw.WordIdx = uint32(i)
+				k := this.Dwt[dw]
+
+				// remove the current assignment
+				this.Dt.Decr(doc, k, uint32(1))
+				docTopicBucket -= this.Beta / denom[k]
+				wtbCache[k] = (this.Alphas[k] + float32(this.Dt.Get(doc, k))) / denom[k]
+
+				// compute word-topic bucket sum
+				wtbSum := float32(0.0)
+				for idx, _ := range this.Wtm.Data[w] {
+					tid, count := this.Wtm.Get(w, idx)
+					wtbSum += wtbCache[tid] * float32(count)
+				}
+
+				// resample topic assignment
+				var cumsum float32
+				u := this.rng.Float32() * (wtbSum + docTopicBucket + smoothingBucket)
+				if u < wtbSum { // topic-word bucket
+					cumsum = 0.0
+					for tcIdx, _ := range this.Wtm.Data[w] {
+						tid, count := this.Wtm.Get(w, tcIdx)
+						cumsum += wtbCache[tid] * float32(count)
+						k = tid
+						if cumsum >= u {
+							break
+						}
+					}
+				} else if u < wtbSum+docTopicBucket { // doc-topic bucket
+					cumsum = 0.0
+					u = u - wtbSum
+					for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+						cnt := this.Dt.Get(doc, kidx)
+						if cnt == 0 {
+							continue
+						}
+						cumsum += (this.Beta * float32(cnt)) / denom[kidx]
+						k = kidx
+						if cumsum >= u {
+							break
+						}
+					}
+				} else { // smoothing bucket
+					cumsum = 0.0
+					u = u - wtbSum - docTopicBucket
+					for kidx := uint32(0); kidx < this.TopicNum; kidx += 1 {
+						cumsum += (this.Alphas[kidx] * this.Beta) / denom[kidx]
+						k = kidx
+						if cumsum >= u {
+							break
+						}
+					}
+				}
+
+				// add the new assignment
+				this.Dt.Incr(doc, k, uint32(1))
+				docTopicBucket += this.Beta / denom[k]
+				wtbCache[k] = (this.Alphas[k] + float32(this.Dt.Get(doc, k))) / denom[k]
+				this.Dwt[dw] = k
+			}
+		}
+	}
 }
 
-// compute the posterior point estimation of word-topic mixture
-// beta (Dirichlet prior) + data -> phi
+// get the word-topic mixture averaged over samples, or the one of the
+// current state if no sample is taken
 func (this *SparseLDA) Phi() *sstable.Float32Matrix {
-	phi := sstable.NewFloat32Matrix(this.Data.VocabSize, this.TopicNum)
+	if this.phiSum != nil {
+		return this.phiSum.mean()
+	}
+	return this.statePhi()
+}
+
+// compute the posterior point estimation of word-topic mixture
+// beta (Dirichlet prior) + data -> phi, the vocabulary is the one of
+// Wtm so phi of a loaded model can be computed without corpus
+func (this *SparseLDA) statePhi() *sstable.Float32Matrix {
+	vocabSize := this.Wtm.MaxWordId + uint32(1)
+	phi := sstable.NewFloat32Matrix(vocabSize, this.TopicNum)
 
-	for w := uint32(0); w < this.Data.VocabSize; w += 1 {
+	for w := uint32(0); w < vocabSize; w += 1 {
 		// convert sparse vector to dense vector
 		wordTopicCount := make([]uint32, this.TopicNum)
 		for tcIdx, _ := range this.Wtm.Data[w] {
@@ -196,7 +408,7 @@ func (this *SparseLDA) Phi() *sstable.Float32Matrix {
 		for k := uint32(0); k < this.TopicNum; k += 1 {
 			result := (float32(wordTopicCount[k]) + this.Beta) /
 				(float32(this.Wts.Get(k, uint32(0))) +
-					float32(this.Data.VocabSize)*this.Beta)
+					float32(vocabSize)*this.Beta)
 			phi.Set(w, k, result)
 		}
 	}
@@ -213,37 +425,42 @@ func (this *SparseLDA) SavePhi(fn string) error {
 	return nil
 }
 
-// compute the joint likelihood of corpus
+// compute the joint likelihood of corpus under the current state
 func (this *SparseLDA) Likelihood() float64 {
-	phi := this.Phi()
-	theta := this.Theta()
-
-	sum := float64(0.0)
-	for doc, wcs := range this.Data.Docs {
-		for _, w := range corpus.ExpandWords(wcs) {
-			topicSum := float32(0.0)
-			for k := uint32(0); k < this.TopicNum; k += 1 {
-				topicSum += phi.Get(w, k) * theta.Get(doc, k)
-			}
-			sum += math.Log(float64(topicSum))
-		}
-	}
-
-	return sum
+	return this.likelihood(this.statePhi(), this.stateTheta())
 }
 
-// serialize word-topic matrix
+// serialize word-topic matrix, the hyperparameters are saved in
+// another file with suffix .hyper
 func (this *SparseLDA) SaveWordTopic(fn string) error {
 	if err := this.Wtm.Serialize(fn); err != nil {
 		return err
 	}
+	if err := this.saveHyperParams(fn + ".hyper"); err != nil {
+		return err
+	}
 	return nil
 }
 
-// deserialize word-topic matrix
+// deserialize word-topic matrix and hyperparameters
 func (this *SparseLDA) LoadWordTopic(fn string) error {
 	if err := this.Wtm.Deserialize(fn); err != nil {
 		return err
 	}
+	if err := this.loadHyperParams(fn + ".hyper"); err != nil {
+		return err
+	}
+	// init WordTopicSum table
+	this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	for w, _ := range this.Wtm.Data {
+		for idx, _ := range this.Wtm.Data[w] {
+			topicId, count := this.Wtm.Get(w, idx)
+			if topicId >= this.TopicNum {
+				return fmt.Errorf("topic %d of word %d exceeds topic number %d",
+					topicId, w, this.TopicNum)
+			}
+			this.Wts.Incr(topicId, uint32(0), count)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func TestSparseLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewSparseLDA(uint32(4), float32(0.1), float32(0.01)).(*SparseLDA)
+	m.Seed(1)
+	m.Train(dat, 10)
+
+	wt := m.wordTopic()
+	assertCounts(t, dat, m.TopicNum, wt, m.Dt, m.Wts)
+	assertDocWordTopics(t, dat, m.TopicNum, wt, m.Dt, m.Dwt)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	// the word-topic counts are held fixed in inference
+	wts := sstable.NewUint32Matrix(m.TopicNum, uint32(1))
+	for k := uint32(0); k < m.TopicNum; k += 1 {
+		wts.Set(k, uint32(0), m.Wts.Get(k, uint32(0)))
+	}
+	heldOut := newHeldOutCorpus()
+	m.Infer(heldOut, 5)
+	assert.Equal(t, wt, m.wordTopic())
+	assert.Equal(t, wts, m.Wts)
+
+	docLen, _ := tokenCounts(heldOut)
+	for doc := uint32(0); doc < heldOut.DocNum; doc += 1 {
+		assert.Equal(t, docLen[doc], sstable.Uint32VectorSum(m.Dt.GetRow(doc)))
+	}
+	assertDistributions(t, m.Phi(), m.Theta())
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"math"
+)
+
+// compute the digamma function, i.e. the derivative of the log gamma
+// function, by the recurrence for small x and the asymptotic series
+// for large x
+func digamma(x float64) float64 {
+	result := 0.0
+	for x < 6.0 {
+		result -= 1.0 / x
+		x += 1.0
+	}
+	f := 1.0 / (x * x)
+	result += math.Log(x) - 0.5/x -
+		f*(1.0/12.0-f*(1.0/120.0-f*(1.0/252.0-f*(1.0/240.0-f/132.0))))
+	return result
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"fmt"
+
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+)
+
+func init() {
+	Register("vblda", NewVBLDA)
+}
+
+// VBLDA is the batch mean-field variational bayes algorithm of Blei,
+// Ng and Jordan (2003) with a Dirichlet prior on topics. Every
+// iteration fits gamma of all documents with topics held fixed and
+// then sets lambda to eta plus the expected word-topic counts, which is
+// the online update with the whole corpus as one minibatch and learning
+// rate one. Training the same corpus gives the same model only if the
+// random initialization is seeded by Seed.
+type VBLDA struct {
+	*OnlineLDA
+}
+
+// NewVBLDA creates a batch variational bayes LDA instance
+func NewVBLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &VBLDA{
+		OnlineLDA: NewOnlineLDA(topicNum, alpha, beta).(*OnlineLDA),
+	}
+}
+
+// batch vb has no learning rate or minibatch to set
+func (this *VBLDA) SetHyperParam(name string, value float64) error {
+	return fmt.Errorf("unknown hyperparameter of vblda: %s", name)
+}
+
+// batch vb needs the whole corpus in every iteration
+func (this *VBLDA) TrainStream(it corpus.DocumentIterator) error {
+	return fmt.Errorf("vblda does not support streaming, use onlinelda instead")
+}
+
+func (this *VBLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	this.Data = dat
+	this.DocNum = dat.DocNum
+	this.VocabSize = dat.VocabSize
+	this.Lambda = nil
+	this.initLambda()
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			log.Infof("iter %5d", iterIdx)
+		}
+		this.update(dat, 1.0)
+	}
+
+	this.inferGamma(100)
+}
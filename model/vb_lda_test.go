@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVBLDA(t *testing.T) {
+	dat := newTestCorpus()
+	m := NewVBLDA(uint32(4), float32(0.1), float32(0.01)).(*VBLDA)
+	m.Train(dat, 5)
+	assertDistributions(t, m.Phi(), m.Theta())
+
+	// lambda is eta plus the expected counts of all the tokens
+	docLen, _ := tokenCounts(dat)
+	expected := float64(m.Eta) * float64(dat.VocabSize) * float64(m.TopicNum)
+	for _, n := range docLen {
+		expected += float64(n)
+	}
+	assert.InDelta(t, expected, lambdaTotal(m.OnlineLDA), 1e-3*expected)
+
+	m.Infer(dat, 5)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
+
+func TestVBLDASeed(t *testing.T) {
+	dat := newTestCorpus()
+	models := make([]*VBLDA, 2)
+	for i, _ := range models {
+		models[i] = NewVBLDA(uint32(4), float32(0.1), float32(0.01)).(*VBLDA)
+		models[i].Seed(7)
+		models[i].Train(dat, 3)
+	}
+	assert.Equal(t, models[0].Lambda, models[1].Lambda)
+}
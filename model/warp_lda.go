@@ -0,0 +1,332 @@
+package model
+
+import (
+	log "github.com/golang/glog"
+
+	"github.com/bobonovski/gotm/corpus"
+	"github.com/bobonovski/gotm/sstable"
+)
+
+func init() {
+	Register("warplda", NewWarpLDA)
+}
+
+// WarpLDA alternates a word-major pass and a document-major pass over
+// the tokens. In the word pass the document proposals drawn in the
+// previous doc pass are accepted or rejected using only the counts of
+// current word, and new word proposals q(k) ~ n_wk+beta are drawn. In
+// the doc pass the word proposals are accepted or rejected using only
+// the counts of current document, and new document proposals
+// q(k) ~ n_dk+alpha are drawn. Counts are only recomputed when a word
+// or document is visited (delayed update), so every token costs O(1)
+// and each pass reads a contiguous block of memory. Topic assignments
+// are kept in flat slices instead of the doc-word-topic map.
+type WarpLDA struct {
+	*ldaBase
+	MHSteps uint32 // number of metropolis hastings proposals per token
+
+	docIds     []uint32 // document id of each document block
+	docOffset  []uint32 // token range of each document in doc-major order
+	wordOffset []uint32 // token range of each word in word-major order
+	wordToken  []uint32 // doc-major position of each word-major token
+	words      []uint32 // word of each doc-major token
+	topics     []uint32 // topic of each doc-major token
+	proposals  []uint32 // MHSteps proposals of each doc-major token
+	topicCount []uint32 // topic counts n_k of last pass
+	filled     []uint32 // topics the dense count buffer is filled with
+	vocabSize  uint32   // vocabulary size of the model
+}
+
+// NewWarpLDA creates a warp lda instance with O(1) metropolis hastings
+// sampler and cache friendly memory access
+func NewWarpLDA(topicNum uint32, alpha float32, beta float32) Model {
+	return &WarpLDA{
+		ldaBase: newLDABase(topicNum, alpha, beta),
+		MHSteps: uint32(2),
+	}
+}
+
+// lay out tokens in doc-major order and build the word-major index
+func (this *WarpLDA) initTokens() {
+	this.docIds = this.Data.DocIds()
+
+	this.docOffset = make([]uint32, 0, len(this.docIds)+1)
+	this.words = this.words[:0]
+	wordCount := make([]uint32, this.Data.VocabSize)
+	for _, doc := range this.docIds {
+		this.docOffset = append(this.docOffset, uint32(len(this.words)))
+		for _, w := range corpus.ExpandWords(this.Data.Docs[doc]) {
+			this.words = append(this.words, w)
+			wordCount[w] += 1
+		}
+	}
+	this.docOffset = append(this.docOffset, uint32(len(this.words)))
+
+	// counting sort tokens by word
+	this.wordOffset = make([]uint32, this.Data.VocabSize+uint32(1))
+	for w := uint32(0); w < this.Data.VocabSize; w += 1 {
+		this.wordOffset[w+1] = this.wordOffset[w] + wordCount[w]
+	}
+	this.wordToken = make([]uint32, len(this.words))
+	next := make([]uint32, this.Data.VocabSize)
+	copy(next, this.wordOffset)
+	for pos, w := range this.words {
+		this.wordToken[next[w]] = uint32(pos)
+		next[w] += 1
+	}
+
+	// randomly assign topic to word
+	this.topics = make([]uint32, len(this.words))
+	for pos, _ := range this.topics {
+		this.topics[pos] = uint32(this.rng.Int31n(int32(this.TopicNum)))
+	}
+	this.proposals = make([]uint32, len(this.words)*int(this.MHSteps))
+	this.topicCount = make([]uint32, this.TopicNum)
+	this.countTopics()
+}
+
+// recompute topic counts n_k from assignments
+func (this *WarpLDA) countTopics() {
+	for k, _ := range this.topicCount {
+		this.topicCount[k] = 0
+	}
+	for _, k := range this.topics {
+		this.topicCount[k] += 1
+	}
+}
+
+// draw document proposals q(k) ~ n_dk+alpha for all tokens, a
+// proposal is either the topic of a random token in the document or
+// a uniformly drawn topic
+func (this *WarpLDA) proposeDoc(begin, end uint32) {
+	length := float32(end - begin)
+	norm := length + float32(this.TopicNum)*this.Alpha
+	for pos := begin; pos < end; pos += 1 {
+		for m := uint32(0); m < this.MHSteps; m += 1 {
+			var t uint32
+			if this.rng.Float32()*norm < length {
+				t = this.topics[begin+uint32(this.rng.Int63n(int64(end-begin)))]
+			} else {
+				t = uint32(this.rng.Int31n(int32(this.TopicNum)))
+			}
+			this.proposals[pos*this.MHSteps+m] = t
+		}
+	}
+}
+
+// fill the dense count buffer with the topics in filled, the topics
+// are copied to filled before the acceptance step changes them so the
+// buffer can be reset afterwards
+func (this *WarpLDA) fillBuffer(buffer []uint32) {
+	for _, k := range this.filled {
+		buffer[k] += 1
+	}
+}
+
+// reset the slots of the dense count buffer set by fillBuffer
+func (this *WarpLDA) resetBuffer(buffer []uint32) {
+	for _, k := range this.filled {
+		buffer[k] = 0
+	}
+}
+
+// word-major pass, accept document proposals and draw word proposals.
+// The word topic counts are held fixed while the proposals of a word
+// are accepted (delayed update), the word proposals are drawn from the
+// topics after acceptance
+func (this *WarpLDA) wordPass(wordTopic []uint32) {
+	betaSum := this.Beta * float32(this.vocabSize)
+	for w := uint32(0); w < this.Data.VocabSize; w += 1 {
+		tokens := this.wordToken[this.wordOffset[w]:this.wordOffset[w+1]]
+		if len(tokens) == 0 {
+			continue
+		}
+		this.filled = this.filled[:0]
+		for _, pos := range tokens {
+			this.filled = append(this.filled, this.topics[pos])
+		}
+		this.fillBuffer(wordTopic)
+
+		// accept or reject document proposals
+		for _, pos := range tokens {
+			s := this.topics[pos]
+			for m := uint32(0); m < this.MHSteps; m += 1 {
+				t := this.proposals[pos*this.MHSteps+m]
+				if t == s {
+					continue
+				}
+				// p(t)q(s) / p(s)q(t) with q(k) ~ n_dk+alpha
+				pt := (float32(wordTopic[t]) + this.Beta) *
+					(float32(this.topicCount[s]) + betaSum)
+				ps := (float32(wordTopic[s]) + this.Beta) *
+					(float32(this.topicCount[t]) + betaSum)
+				if this.rng.Float32()*ps < pt {
+					s = t
+				}
+			}
+			this.topics[pos] = s
+		}
+		this.resetBuffer(wordTopic)
+
+		// draw word proposals q(k) ~ n_wk+beta
+		length := float32(len(tokens))
+		norm := length + float32(this.TopicNum)*this.Beta
+		for _, pos := range tokens {
+			for m := uint32(0); m < this.MHSteps; m += 1 {
+				var t uint32
+				if this.rng.Float32()*norm < length {
+					t = this.topics[tokens[this.rng.Int63n(int64(len(tokens)))]]
+				} else {
+					t = uint32(this.rng.Int31n(int32(this.TopicNum)))
+				}
+				this.proposals[pos*this.MHSteps+m] = t
+			}
+		}
+	}
+}
+
+// document-major pass, accept word proposals and draw doc proposals
+func (this *WarpLDA) docPass(docTopic []uint32) {
+	betaSum := this.Beta * float32(this.vocabSize)
+	for d, _ := range this.docIds {
+		begin, end := this.docOffset[d], this.docOffset[d+1]
+		if begin == end {
+			continue
+		}
+		this.filled = append(this.filled[:0], this.topics[begin:end]...)
+		this.fillBuffer(docTopic)
+
+		// accept or reject word proposals
+		for pos := begin; pos < end; pos += 1 {
+			s := this.topics[pos]
+			for m := uint32(0); m < this.MHSteps; m += 1 {
+				t := this.proposals[pos*this.MHSteps+m]
+				if t == s {
+					continue
+				}
+				// p(t)q(s) / p(s)q(t) with q(k) ~ n_wk+beta
+				pt := (float32(docTopic[t]) + this.Alpha) *
+					(float32(this.topicCount[s]) + betaSum)
+				ps := (float32(docTopic[s]) + this.Alpha) *
+					(float32(this.topicCount[t]) + betaSum)
+				if this.rng.Float32()*ps < pt {
+					s = t
+				}
+			}
+			this.topics[pos] = s
+		}
+
+		this.resetBuffer(docTopic)
+
+		this.proposeDoc(begin, end)
+	}
+}
+
+// fill the count tables from topic assignments, Wt and Wts are only
+// touched if updateWords is true
+func (this *WarpLDA) updateTables(updateWords bool) {
+	this.Dt = sstable.NewUint32Matrix(this.Data.DocNum, this.TopicNum)
+	if updateWords {
+		this.Wt = sstable.NewUint32Matrix(this.Data.VocabSize, this.TopicNum)
+		this.Wts = sstable.NewUint32Matrix(this.TopicNum, uint32(1))
+	}
+	for d, doc := range this.docIds {
+		for pos := this.docOffset[d]; pos < this.docOffset[d+1]; pos += 1 {
+			k := this.topics[pos]
+			this.Dt.Incr(doc, k, uint32(1))
+			if updateWords {
+				this.Wt.Incr(this.words[pos], k, uint32(1))
+				this.Wts.Incr(k, uint32(0), uint32(1))
+			}
+		}
+	}
+}
+
+func (this *WarpLDA) ResampleTopics(iter int) {
+	buffer := make([]uint32, this.TopicNum)
+
+	// draw the initial document proposals
+	for d, _ := range this.docIds {
+		this.proposeDoc(this.docOffset[d], this.docOffset[d+1])
+	}
+
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		if log.V(5) {
+			if iterIdx%10 == 0 {
+				this.updateTables(true)
+				log.Infof("iter %5d, likelihood %f", iterIdx, this.Likelihood())
+			}
+		}
+		this.wordPass(buffer)
+		this.countTopics()
+		this.docPass(buffer)
+		this.countTopics()
+	}
+
+	this.updateTables(true)
+}
+
+func (this *WarpLDA) Train(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	this.Data = dat
+	this.vocabSize = dat.VocabSize
+
+	// randomly init topic assignments
+	this.initTokens()
+
+	this.ResampleTopics(iter)
+}
+
+// infer topics on new documents, the loaded word-topic counts are
+// held fixed so the word proposals are drawn from per-word alias
+// tables and only the document pass is run
+func (this *WarpLDA) Infer(dat *corpus.Corpus, iter int) {
+	if dat == nil {
+		log.Fatal("corpus is nil")
+	}
+	if this.Wt == nil || this.Wts == nil {
+		log.Fatal("Wt or Wts is not initialized, maybe model is not loaded")
+	}
+	this.Data = dat
+	this.initTokens()
+
+	// topic counts and word alias tables of the loaded model
+	vocabSize, _ := this.Wt.Shape()
+	this.vocabSize = vocabSize
+	for k := uint32(0); k < this.TopicNum; k += 1 {
+		this.topicCount[k] = this.Wts.Get(k, uint32(0))
+	}
+	tables := make(map[uint32]*sstable.AliasTable)
+	weights := make([]float32, this.TopicNum)
+	for _, w := range this.words {
+		if _, ok := tables[w]; ok || w >= vocabSize {
+			continue
+		}
+		for k := uint32(0); k < this.TopicNum; k += 1 {
+			weights[k] = float32(this.Wt.Get(w, k)) + this.Beta
+		}
+		tables[w] = sstable.NewAliasTable(this.TopicNum)
+		tables[w].Build(weights)
+	}
+
+	buffer := make([]uint32, this.TopicNum)
+	for iterIdx := 0; iterIdx < iter; iterIdx += 1 {
+		// draw exact word proposals from the fixed counts
+		for pos, w := range this.words {
+			for m := uint32(0); m < this.MHSteps; m += 1 {
+				var t uint32
+				if table, ok := tables[w]; ok {
+					t = table.Sample(this.rng.Float32())
+				} else {
+					t = uint32(this.rng.Int31n(int32(this.TopicNum)))
+				}
+				this.proposals[uint32(pos)*this.MHSteps+m] = t
+			}
+		}
+		this.docPass(buffer)
+	}
+
+	this.updateTables(false)
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarpLDAPass(t *testing.T) {
+	m := NewWarpLDA(uint32(8), float32(0.1), float32(0.01)).(*WarpLDA)
+	m.Seed(1)
+	m.Data = newTestCorpus()
+	m.vocabSize = m.Data.VocabSize
+	m.initTokens()
+	for d, _ := range m.docIds {
+		m.proposeDoc(m.docOffset[d], m.docOffset[d+1])
+	}
+
+	buffer := make([]uint32, m.TopicNum)
+	for iterIdx := 0; iterIdx < 5; iterIdx += 1 {
+		m.wordPass(buffer)
+		assert.Equal(t, make([]uint32, m.TopicNum), buffer)
+		m.countTopics()
+		m.docPass(buffer)
+		assert.Equal(t, make([]uint32, m.TopicNum), buffer)
+		m.countTopics()
+	}
+
+	m.updateTables(true)
+	for k := uint32(0); k < m.TopicNum; k += 1 {
+		assert.Equal(t, m.topicCount[k], m.Wts.Get(k, uint32(0)))
+	}
+	for d, doc := range m.docIds {
+		for pos := m.docOffset[d]; pos < m.docOffset[d+1]; pos += 1 {
+			assert.True(t, m.Dt.Get(doc, m.topics[pos]) > 0)
+			assert.True(t, m.Wt.Get(m.words[pos], m.topics[pos]) > 0)
+		}
+	}
+	assertCounts(t, m.Data, m.TopicNum, m.Wt, m.Dt, m.Wts)
+}
+
+func TestWarpLDATrain(t *testing.T) {
+	m := NewWarpLDA(uint32(4), float32(0.1), float32(0.01)).(*WarpLDA)
+	m.Seed(1)
+	m.Train(newTestCorpus(), 10)
+
+	assertCounts(t, m.Data, m.TopicNum, m.Wt, m.Dt, m.Wts)
+	assertDistributions(t, m.Phi(), m.Theta())
+}
@@ -0,0 +1,94 @@
+package sstable
+
+// AliasTable draws samples from a discrete distribution in O(1)
+// time after O(n) construction using Vose's alias method. The
+// unnormalized weights used for construction are kept in Weight
+// so that samplers can compute proposal probabilities of a stale
+// table, and their summation is kept in Sum.
+type AliasTable struct {
+	Weight []float32
+	Sum    float32
+	prob   []float32
+	alias  []uint32
+}
+
+// NewAliasTable creates an alias table with room for n outcomes
+func NewAliasTable(n uint32) *AliasTable {
+	if n <= 0 {
+		panic(ErrBadShape)
+	}
+	return &AliasTable{
+		Weight: make([]float32, n),
+		prob:   make([]float32, n),
+		alias:  make([]uint32, n),
+	}
+}
+
+// Build (re)constructs the table from the unnormalized weights,
+// the length of weights should be the same as the table size
+func (this *AliasTable) Build(weights []float32) {
+	n := len(this.prob)
+	if len(weights) != n {
+		panic(ErrIndexOutOfRange)
+	}
+	copy(this.Weight, weights)
+	this.Sum = Float32VectorSum(weights)
+	if this.Sum <= 0 {
+		// degenerate distribution, fall back to uniform
+		for i := 0; i < n; i += 1 {
+			this.prob[i] = 1.0
+			this.alias[i] = uint32(i)
+		}
+		return
+	}
+
+	small := make([]uint32, 0, n)
+	large := make([]uint32, 0, n)
+	for i, w := range weights {
+		this.prob[i] = w * float32(n) / this.Sum
+		if this.prob[i] < 1.0 {
+			small = append(small, uint32(i))
+		} else {
+			large = append(large, uint32(i))
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		this.alias[s] = l
+		this.prob[l] = (this.prob[l] + this.prob[s]) - 1.0
+		if this.prob[l] < 1.0 {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+	// the remaining entries are full up to numerical error
+	for _, l := range large {
+		this.prob[l] = 1.0
+		this.alias[l] = l
+	}
+	for _, s := range small {
+		this.prob[s] = 1.0
+		this.alias[s] = s
+	}
+}
+
+// Sample draws one outcome using a uniform random number u in [0, 1),
+// the integer part of u*n selects the column and the fractional part
+// decides between the column and its alias
+func (this *AliasTable) Sample(u float32) uint32 {
+	n := len(this.prob)
+	x := u * float32(n)
+	col := int(x)
+	if col >= n {
+		col = n - 1
+	}
+	if x-float32(col) < this.prob[col] {
+		return uint32(col)
+	}
+	return this.alias[col]
+}
@@ -0,0 +1,25 @@
+package sstable
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAliasTable(t *testing.T) {
+	m := NewAliasTable(uint32(4))
+	m.Build([]float32{1.0, 0.0, 2.0, 1.0})
+	assert.Equal(t, float32(4.0), m.Sum)
+	assert.Equal(t, float32(2.0), m.Weight[2])
+
+	// count outcomes over an evenly spaced grid of uniform numbers
+	counts := make([]int, 4)
+	n := 4000
+	for i := 0; i < n; i += 1 {
+		counts[m.Sample((float32(i)+0.5)/float32(n))] += 1
+	}
+	assert.Equal(t, 1000, counts[0])
+	assert.Equal(t, 0, counts[1])
+	assert.Equal(t, 2000, counts[2])
+	assert.Equal(t, 1000, counts[3])
+}
@@ -0,0 +1,60 @@
+package sstable
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Assignment keeps the topic of every word of documents, the row of a
+// document is allocated once by Alloc and afterwards Get and Set can
+// be called from multiple goroutines, which is not allowed for the
+// doc-word-topic map
+type Assignment struct {
+	mu   sync.RWMutex
+	data map[uint32][]uint32
+}
+
+// NewAssignment creates an empty assignment store
+func NewAssignment() *Assignment {
+	return &Assignment{
+		data: make(map[uint32][]uint32),
+	}
+}
+
+// allocate the topics of n words of document doc, the old topics of
+// the document are dropped
+func (this *Assignment) Alloc(doc uint32, n uint32) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.data[doc] = make([]uint32, n)
+}
+
+// get the row of document doc
+func (this *Assignment) row(doc uint32) []uint32 {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return this.data[doc]
+}
+
+// get the number of words of document doc
+func (this *Assignment) Len(doc uint32) uint32 {
+	return uint32(len(this.row(doc)))
+}
+
+// get the topic of the idx-th word of document doc
+func (this *Assignment) Get(doc uint32, idx uint32) uint32 {
+	row := this.row(doc)
+	if idx >= uint32(len(row)) {
+		panic(ErrIndexOutOfRange)
+	}
+	return atomic.LoadUint32(&row[idx])
+}
+
+// set topic k to the idx-th word of document doc
+func (this *Assignment) Set(doc uint32, idx uint32, k uint32) {
+	row := this.row(doc)
+	if idx >= uint32(len(row)) {
+		panic(ErrIndexOutOfRange)
+	}
+	atomic.StoreUint32(&row[idx], k)
+}
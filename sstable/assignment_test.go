@@ -0,0 +1,46 @@
+package sstable
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssignment(t *testing.T) {
+	m := NewAssignment()
+	m.Alloc(uint32(3), uint32(2))
+	assert.Equal(t, uint32(2), m.Len(uint32(3)))
+	assert.Equal(t, uint32(0), m.Len(uint32(4)))
+
+	m.Set(uint32(3), uint32(1), uint32(5))
+	assert.Equal(t, uint32(0), m.Get(uint32(3), uint32(0)))
+	assert.Equal(t, uint32(5), m.Get(uint32(3), uint32(1)))
+	assert.Panics(t, func() { m.Get(uint32(3), uint32(2)) })
+	assert.Panics(t, func() { m.Set(uint32(4), uint32(0), uint32(1)) })
+}
+
+func TestAssignmentConcurrent(t *testing.T) {
+	m := NewAssignment()
+	for doc := uint32(0); doc < 8; doc += 1 {
+		m.Alloc(doc, uint32(100))
+	}
+
+	var wg sync.WaitGroup
+	for doc := uint32(0); doc < 8; doc += 1 {
+		wg.Add(1)
+		go func(doc uint32) {
+			defer wg.Done()
+			for i := uint32(0); i < 100; i += 1 {
+				m.Set(doc, i, doc+i)
+			}
+		}(doc)
+	}
+	wg.Wait()
+
+	for doc := uint32(0); doc < 8; doc += 1 {
+		for i := uint32(0); i < 100; i += 1 {
+			assert.Equal(t, doc+i, m.Get(doc, i))
+		}
+	}
+}
@@ -37,6 +37,40 @@ func Float32Serialize(m *Float32Matrix, fn string) error {
 	return nil
 }
 
+// serialize data to file like Float32Serialize, but every row is
+// written with its key instead of its index, keys containing commas or
+// line breaks are rejected since the file could not be parsed
+func Float32SerializeRows(m *Float32Matrix, keys []string, fn string) error {
+	r, c := m.Shape()
+	if uint32(len(keys)) != r {
+		return fmt.Errorf("%d row keys of matrix with %d rows", len(keys), r)
+	}
+	for _, key := range keys {
+		if strings.ContainsAny(key, ",\r\n") {
+			return fmt.Errorf("row key %q contains comma or line break", key)
+		}
+	}
+	out, err := os.OpenFile(fn, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// write the matrix shape
+	out.WriteString(fmt.Sprintf("%d,%d\n", r, c))
+
+	var val float32
+	for ridx := uint32(0); ridx < r; ridx += 1 {
+		for cidx := uint32(0); cidx < c; cidx += 1 {
+			val = m.Get(ridx, cidx)
+			if val > 0 { // only write out nonzero value
+				out.WriteString(fmt.Sprintf("%s,%d,%e\n", keys[ridx], cidx, val))
+			}
+		}
+	}
+	return nil
+}
+
 // deserialize data from file
 func Float32Deserialize(fn string) (*Float32Matrix, error) {
 	file, err := os.Open(fn)
@@ -0,0 +1,26 @@
+package sstable
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloat32SerializeRows(t *testing.T) {
+	m := NewFloat32Matrix(uint32(2), uint32(2))
+	m.Set(uint32(0), uint32(1), float32(0.5))
+	m.Set(uint32(1), uint32(0), float32(0.25))
+
+	fn := filepath.Join(t.TempDir(), "theta")
+	assert.NotNil(t, Float32SerializeRows(m, []string{"a"}, fn))
+	assert.Nil(t, Float32SerializeRows(m, []string{"doc-a", "doc-b"}, fn))
+
+	data, err := ioutil.ReadFile(fn)
+	assert.Nil(t, err)
+	assert.Equal(t, "2,2\ndoc-a,1,5.000000e-01\ndoc-b,0,2.500000e-01\n", string(data))
+
+	assert.NotNil(t, Float32SerializeRows(m, []string{"doc,a", "doc-b"}, fn))
+	assert.NotNil(t, Float32SerializeRows(m, []string{"doc-a", "doc\nb"}, fn))
+}
@@ -0,0 +1,85 @@
+package sstable
+
+// FTree is a F+ tree, i.e. a complete binary tree stored in an array
+// where each leaf holds the weight of one outcome and each internal
+// node holds the summation of its children. Updating one weight and
+// drawing one sample both take O(log n) time.
+type FTree struct {
+	size   uint32 // number of outcomes
+	leaves uint32 // index of the first leaf
+	data   []float32
+}
+
+// NewFTree creates a F+ tree with n outcomes of zero weight
+func NewFTree(n uint32) *FTree {
+	if n <= 0 {
+		panic(ErrBadShape)
+	}
+	leaves := uint32(1)
+	for leaves < n {
+		leaves <<= 1
+	}
+	return &FTree{
+		size:   n,
+		leaves: leaves,
+		data:   make([]float32, 2*leaves),
+	}
+}
+
+// Build resets all the weights of the tree in O(n) time
+func (this *FTree) Build(weights []float32) {
+	if uint32(len(weights)) != this.size {
+		panic(ErrIndexOutOfRange)
+	}
+	copy(this.data[this.leaves:], weights)
+	for i := this.leaves - 1; i > 0; i -= 1 {
+		this.data[i] = this.data[2*i] + this.data[2*i+1]
+	}
+}
+
+// get the weight of the i-th outcome
+func (this *FTree) Get(i uint32) float32 {
+	if i >= this.size {
+		panic(ErrIndexOutOfRange)
+	}
+	return this.data[this.leaves+i]
+}
+
+// set the weight of the i-th outcome and update its ancestors, the
+// ancestors are recomputed from their children instead of applying
+// deltas so that rounding errors do not accumulate
+func (this *FTree) Set(i uint32, val float32) {
+	if i >= this.size {
+		panic(ErrIndexOutOfRange)
+	}
+	idx := this.leaves + i
+	this.data[idx] = val
+	for idx >>= 1; idx > 0; idx >>= 1 {
+		this.data[idx] = this.data[2*idx] + this.data[2*idx+1]
+	}
+}
+
+// get the summation of all the weights
+func (this *FTree) Sum() float32 {
+	return this.data[1]
+}
+
+// Sample finds the outcome whose cumulative weight interval contains
+// u, where u should be in [0, Sum())
+func (this *FTree) Sample(u float32) uint32 {
+	idx := uint32(1)
+	for idx < this.leaves {
+		left := 2 * idx
+		if u < this.data[left] || this.data[left+1] <= 0 {
+			idx = left
+		} else {
+			u -= this.data[left]
+			idx = left + 1
+		}
+	}
+	i := idx - this.leaves
+	if i >= this.size {
+		i = this.size - 1
+	}
+	return i
+}
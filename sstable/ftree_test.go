@@ -0,0 +1,26 @@
+package sstable
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFTree(t *testing.T) {
+	m := NewFTree(uint32(5))
+	m.Build([]float32{1.0, 0.0, 2.0, 1.0, 4.0})
+	assert.Equal(t, float32(8.0), m.Sum())
+	assert.Equal(t, float32(2.0), m.Get(2))
+
+	assert.Equal(t, uint32(0), m.Sample(0.5))
+	assert.Equal(t, uint32(2), m.Sample(1.0))
+	assert.Equal(t, uint32(2), m.Sample(2.5))
+	assert.Equal(t, uint32(3), m.Sample(3.5))
+	assert.Equal(t, uint32(4), m.Sample(7.9))
+
+	m.Set(uint32(4), float32(0.0))
+	m.Set(uint32(1), float32(3.0))
+	assert.Equal(t, float32(7.0), m.Sum())
+	assert.Equal(t, uint32(1), m.Sample(1.5))
+	assert.Equal(t, uint32(3), m.Sample(6.9))
+}
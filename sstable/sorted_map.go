@@ -64,7 +64,8 @@ func (this *SortedMap) Serialize(fn string) error {
 	return nil
 }
 
-// deserialize data from file
+// deserialize data from file, the receiver keeps its topic bit
+// layout and the loaded counts replace its current content
 func (this *SortedMap) Deserialize(fn string) error {
 	file, err := os.Open(fn)
 	if err != nil {
@@ -73,14 +74,16 @@ func (this *SortedMap) Deserialize(fn string) error {
 	defer file.Close()
 
 	var lineIdx int
-	var tmp *SortedMap
+	this.Data = make(map[uint32][]uint32)
+	this.MaxWordId = 0
+	this.MaxTopicId = 0
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		txt := scanner.Text()
 		if lineIdx == 0 {
 			shape := strings.Split(txt, ",")
-			if len(shape) != 0 {
+			if len(shape) != 2 {
 				return errors.New("model corrupted, shape not found")
 			}
 			row, err := strconv.ParseUint(shape[0], 10, 32)
@@ -91,9 +94,16 @@ func (this *SortedMap) Deserialize(fn string) error {
 			if err != nil {
 				return err
 			}
-			tmp = NewSortedMap(uint32(row))
-			tmp.MaxWordId = uint32(row) - uint32(1)
-			tmp.MaxTopicId = uint32(col) - uint32(1)
+			if row == 0 || col == 0 {
+				return errors.New("model corrupted, empty shape")
+			}
+			if uint32(col)-uint32(1) > this.TopicMask {
+				return fmt.Errorf("model has %d topics, at most %d supported",
+					col, this.TopicMask+uint32(1))
+			}
+			this.MaxWordId = uint32(row) - uint32(1)
+			this.MaxTopicId = uint32(col) - uint32(1)
+			lineIdx += 1
 			continue
 		}
 
@@ -115,8 +125,12 @@ func (this *SortedMap) Deserialize(fn string) error {
 		if err != nil {
 			return err
 		}
+		if uint32(cidx) > this.TopicMask {
+			return fmt.Errorf("data corrupted, row %d, topic %d out of range",
+				lineIdx, cidx)
+		}
 
-		tmp.Incr(uint32(ridx), uint32(cidx), uint32(val))
+		this.Incr(uint32(ridx), uint32(cidx), uint32(val))
 
 		lineIdx += 1
 	}
@@ -125,8 +139,6 @@ func (this *SortedMap) Deserialize(fn string) error {
 		return err
 	}
 
-	this = tmp
-
 	return nil
 }
 
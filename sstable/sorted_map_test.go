@@ -1,6 +1,7 @@
 package sstable
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,3 +35,23 @@ func TestSortedMap(t *testing.T) {
 	assert.Equal(t, uint32(1), tid)
 	assert.Equal(t, uint32(7), count)
 }
+
+func TestSortedMapSerialization(t *testing.T) {
+	m := NewSortedMap(uint32(10))
+	m.Incr(uint32(0), uint32(3), uint32(2))
+	m.Incr(uint32(2), uint32(9), uint32(5))
+	m.Incr(uint32(2), uint32(1), uint32(7))
+
+	fn := filepath.Join(t.TempDir(), "sorted_map")
+	assert.Nil(t, m.Serialize(fn))
+
+	n := NewSortedMap(uint32(10))
+	assert.Nil(t, n.Deserialize(fn))
+	assert.Equal(t, m.Data, n.Data)
+	assert.Equal(t, uint32(2), n.MaxWordId)
+	assert.Equal(t, uint32(9), n.MaxTopicId)
+
+	// too many topics for the bit layout of receiver
+	small := NewSortedMap(uint32(2))
+	assert.NotNil(t, small.Deserialize(fn))
+}
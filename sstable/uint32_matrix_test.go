@@ -43,3 +43,23 @@ func TestUint32MatrixIncrDecr(t *testing.T) {
 	m.Decr(uint32(1), uint32(1), uint32(1))
 	assert.Equal(t, uint32(1), m.Get(uint32(1), uint32(1)))
 }
+
+func TestUint32MatrixMarshalBinary(t *testing.T) {
+	m := NewUint32Matrix(uint32(2), uint32(3))
+	m.Set(uint32(0), uint32(1), uint32(7))
+	m.Set(uint32(1), uint32(2), uint32(9))
+
+	buf, err := m.MarshalBinary()
+	assert.Nil(t, err)
+
+	v := &Uint32Matrix{}
+	assert.Nil(t, v.UnmarshalBinary(buf))
+	r, c := v.Shape()
+	assert.Equal(t, uint32(2), r)
+	assert.Equal(t, uint32(3), c)
+	assert.Equal(t, uint32(7), v.Get(uint32(0), uint32(1)))
+	assert.Equal(t, uint32(9), v.Get(uint32(1), uint32(2)))
+	assert.Equal(t, uint32(0), v.Get(uint32(1), uint32(1)))
+
+	assert.NotNil(t, v.UnmarshalBinary(buf[:10]))
+}
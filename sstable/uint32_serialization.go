@@ -2,10 +2,12 @@ package sstable
 
 import (
 	"bufio"
+	"encoding/binary"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	log "github.com/golang/glog"
 )
@@ -98,3 +100,34 @@ func Uint32Deserialize(fn string) (*Uint32Matrix, error) {
 
 	return tmp, nil
 }
+
+// encode the matrix into binary form, the shape is followed by the
+// elements in row major order, all in little endian, so the matrix can
+// be written by encoding/gob
+func (m *Uint32Matrix) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 8+4*len(m.data))
+	binary.LittleEndian.PutUint32(buf[0:], m.nrow)
+	binary.LittleEndian.PutUint32(buf[4:], m.ncol)
+	for i, _ := range m.data {
+		binary.LittleEndian.PutUint32(buf[8+4*i:], atomic.LoadUint32(&m.data[i]))
+	}
+	return buf, nil
+}
+
+// decode the matrix from the binary form of MarshalBinary
+func (m *Uint32Matrix) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 8 {
+		return fmt.Errorf("matrix: binary data too short: %d", len(buf))
+	}
+	nrow := binary.LittleEndian.Uint32(buf[0:])
+	ncol := binary.LittleEndian.Uint32(buf[4:])
+	if uint64(len(buf)) != 8+4*uint64(nrow)*uint64(ncol) {
+		return fmt.Errorf("matrix: %d bytes for shape %d,%d", len(buf), nrow, ncol)
+	}
+	m.nrow, m.ncol = nrow, ncol
+	m.data = make([]uint32, nrow*ncol)
+	for i, _ := range m.data {
+		m.data[i] = binary.LittleEndian.Uint32(buf[8+4*i:])
+	}
+	return nil
+}
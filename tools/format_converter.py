@@ -25,6 +25,9 @@
    
    gotm assumes the docID start from zero, so the original docID could
    be changed after processing
+
+   gotm also reads UCI files directly with -input_format uci (or auto),
+   which keeps the original docID as document key
 """
 import argparse
 from collections import defaultdict
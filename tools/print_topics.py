@@ -4,10 +4,10 @@ import argparse
 from collections import defaultdict
 import operator
 
-def print_topics(model_file, vocab_file, topk):
+def print_topics(model_file, vocab_file, topk, id_offset):
     vocab = {}
     for i, word in enumerate(open(vocab_file, 'r')):
-        vocab[i+1] = word.strip()
+        vocab[i+id_offset] = word.strip()
 
     topics = defaultdict(list)
     for i, line in enumerate(open(model_file, 'r')):
@@ -27,6 +27,9 @@ if __name__ == '__main__':
     parser.add_argument('--model_file', help='word topic distribution file')
     parser.add_argument('--vocab_file', help='vocabulary file')
     parser.add_argument('--topk', type=int, default=10, help='top k')
+    parser.add_argument('--id_offset', type=int, default=1,
+        help='word id of the first vocabulary line, 1 for UCI vocabularies '
+             'and 0 for vocabularies written by buildcorpus')
     args = parser.parse_args()
 
-    print_topics(args.model_file, args.vocab_file, args.topk)
+    print_topics(args.model_file, args.vocab_file, args.topk, args.id_offset)